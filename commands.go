@@ -0,0 +1,924 @@
+package main
+
+import(
+	"os"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"io/ioutil"
+	"github.com/codegangsta/cli"
+	"github.com/xiconet/utils"
+	pth "path"
+	dbx "github.com/xiconet/dbox/dboxlib"
+)
+
+// subcommands; the global flags (--user...) must precede the command name
+
+var commands = []cli.Command{
+	{
+		Name: "share",
+		Usage: "manage shared folders and their members",
+		Subcommands: []cli.Command{
+			{
+				Name: "folder",
+				Usage: "share the folder at <path>",
+				Action: func(c *cli.Context) {
+					d, path := client(c, 0)
+					folder, err := d.ShareFolder(path)
+					if err != nil {fmt.Println(err); os.Exit(1)}
+					dbx.PrintSharedFolders([]dbx.SharedFolder{folder})
+				},
+			},
+			{
+				Name: "unshare",
+				Usage: "stop sharing the folder <path|id>",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name: "leave_copy, l",
+						Usage: "leave a copy of the folder in the members' Dropbox",
+					},
+				},
+				Action: func(c *cli.Context) {
+					d, id := sharedFolder(c)
+					if err := d.UnshareFolder(id, c.Bool("leave_copy")); err != nil {
+						fmt.Println(err); os.Exit(1)
+					}
+				},
+			},
+			{
+				Name: "list",
+				Usage: "list the shared folders of the current/specified user",
+				Action: func(c *cli.Context) {
+					setOutput(c)
+					d := newClient(c.GlobalString("user"))
+					folders, err := d.SharedFolders()
+					if err != nil {fmt.Println(err); os.Exit(1)}
+					if !dbx.Structured() {
+						fmt.Printf("User: %s\n", d.User)
+					}
+					dbx.PrintSharedFolders(folders)
+				},
+			},
+			{
+				Name: "members",
+				Usage: "list the members of the shared folder <path|id>",
+				Action: func(c *cli.Context) {
+					d, id := sharedFolder(c)
+					members, err := d.FolderMembers(id)
+					if err != nil {fmt.Println(err); os.Exit(1)}
+					dbx.PrintMembers(members)
+				},
+			},
+			{
+				Name: "add",
+				Usage: "add members: share add <path|id> <email> [<email>...]",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name: "access, l",
+						Value: "editor",
+						Usage: fmt.Sprintf("access level, one of %s", strings.Join(dbx.AccessLevels, ", ")),
+					},
+					cli.BoolFlag{
+						Name: "quiet, q",
+						Usage: "do not send invitation emails",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if len(c.Args()) < 2 {
+						fmt.Println("error: missing member email"); os.Exit(2)
+					}
+					d, id := sharedFolder(c)
+					if err := d.AddMembers(id, c.String("access"), c.Args().Tail(), c.Bool("quiet")); err != nil {
+						fmt.Println(err); os.Exit(1)
+					}
+				},
+			},
+			{
+				Name: "update",
+				Usage: "change the access level of a member: share update <path|id> <email>",
+				Flags: []cli.Flag{
+					cli.StringFlag{
+						Name: "access, l",
+						Value: "viewer",
+						Usage: fmt.Sprintf("access level, one of %s", strings.Join(dbx.AccessLevels, ", ")),
+					},
+				},
+				Action: func(c *cli.Context) {
+					if len(c.Args()) < 2 {
+						fmt.Println("error: missing member email"); os.Exit(2)
+					}
+					d, id := sharedFolder(c)
+					if err := d.UpdateMember(id, c.Args().Get(1), c.String("access")); err != nil {
+						fmt.Println(err); os.Exit(1)
+					}
+				},
+			},
+			{
+				Name: "remove",
+				Usage: "remove members: share remove <path|id> <email> [<email>...]",
+				Flags: []cli.Flag{
+					cli.BoolFlag{
+						Name: "leave_copy, l",
+						Usage: "leave a copy of the folder in the member's Dropbox",
+					},
+				},
+				Action: func(c *cli.Context) {
+					if len(c.Args()) < 2 {
+						fmt.Println("error: missing member email"); os.Exit(2)
+					}
+					d, id := sharedFolder(c)
+					for _, email := range c.Args().Tail() {
+						if err := d.RemoveMember(id, email, c.Bool("leave_copy")); err != nil {
+							fmt.Println(err); os.Exit(1)
+						}
+						fmt.Println("removed", email)
+					}
+				},
+			},
+			{
+				Name: "mount",
+				Usage: "mount the shared folder <id>",
+				Action: func(c *cli.Context) {
+					d, id := sharedFolder(c)
+					folder, err := d.MountFolder(id)
+					if err != nil {fmt.Println(err); os.Exit(1)}
+					dbx.PrintSharedFolders([]dbx.SharedFolder{folder})
+				},
+			},
+			{
+				Name: "unmount",
+				Usage: "unmount the shared folder <path|id>",
+				Action: func(c *cli.Context) {
+					d, id := sharedFolder(c)
+					if err := d.UnmountFolder(id); err != nil {
+						fmt.Println(err); os.Exit(1)
+					}
+				},
+			},
+		},
+	},
+	{
+		Name: "revs",
+		Usage: "list the revisions of the file at <path>",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name: "limit, n",
+				Value: 10,
+				Usage: "maximum number of revisions to list (up to 100)",
+			},
+			cli.StringFlag{
+				Name: "get, g",
+				Value: "",
+				Usage: "download revision <rev> of the file instead of listing",
+			},
+			cli.StringFlag{
+				Name: "out, o",
+				Value: "",
+				Usage: "local file path for --get, defaults to <name>.<rev>",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			if rev := c.String("get"); rev != "" {
+				localPath := c.String("out")
+				if localPath == "" {
+					localPath = pth.Base(path) + "." + rev
+				}
+				n, err := d.DownloadRevision(rev, localPath)
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				size, _ := utils.NiceBytes(n)
+				fmt.Printf("saved %s (%s)\n", localPath, size)
+				return
+			}
+			revs, err := d.ListRevisions(path, c.Int("limit"))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			dbx.PrintRevisions(revs)
+		},
+	},
+	{
+		Name: "restore",
+		Usage: "restore the file at <path> to revision <rev>",
+		Action: func(c *cli.Context) {
+			if len(c.Args()) < 2 {
+				fmt.Println("usage: dbox restore <path> <rev>"); os.Exit(2)
+			}
+			d, path := client(c, 0)
+			meta, err := d.Restore(path, c.Args().Get(1))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			meta.User = d.User
+			dbx.PrintMeta(meta)
+		},
+	},
+	{
+		Name: "trash",
+		Usage: "list recently deleted files under [path] with their last revision",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "flat, f",
+				Usage: "do not list deleted entries in subfolders",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			trash, err := d.Trash(path, !c.Bool("flat"))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			if !dbx.Structured() {
+				fmt.Printf("User: %s\n", d.User)
+			}
+			dbx.PrintTrash(trash)
+		},
+	},
+	{
+		Name: "undelete",
+		Usage: "restore the deleted files matching <pattern> to their last revision",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "dry_run, n",
+				Usage: "only show what would be restored",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, pattern := client(c, 0)
+			if pattern == "" {
+				fmt.Println("usage: dbox undelete <pattern>"); os.Exit(2)
+			}
+			restored, err := d.Undelete(pattern, c.Bool("dry_run"))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			for k, _ := range restored {
+				restored.SetUser(d.User, k)
+			}
+			dbx.PrintRestored(restored, c.Bool("dry_run"))
+		},
+	},
+	{
+		Name: "copy",
+		ShortName: "cp",
+		Usage: "copy <src> [<src>...] <dst> on the server, sources may contain wildcards",
+		Flags: relocationFlags,
+		Action: func(c *cli.Context) {
+			d, sources, dst := relocationArgs(c, "copy")
+			results, err := d.CopyPaths(sources, dst, relocationOptions(c))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			if dbx.PrintRelocations(results) > 0 {
+				os.Exit(1)
+			}
+		},
+	},
+	{
+		Name: "mv",
+		Usage: "move <src> [<src>...] <dst> on the server, sources may contain wildcards",
+		Flags: relocationFlags,
+		Action: func(c *cli.Context) {
+			d, sources, dst := relocationArgs(c, "mv")
+			results, err := d.MovePaths(sources, dst, relocationOptions(c))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			if dbx.PrintRelocations(results) > 0 {
+				os.Exit(1)
+			}
+		},
+	},
+	{
+		Name: "rm",
+		Usage: "remove <path> [<path>...], paths may contain wildcards",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "interactive, I",
+				Usage: "ask for confirmation before removing",
+			},
+		},
+		Action: func(c *cli.Context) {
+			if len(c.Args()) == 0 {
+				fmt.Println("usage: dbox rm <path> [<path>...]"); os.Exit(2)
+			}
+			setOutput(c)
+			// each path is removed from its own account, in the order given
+			var users []string
+			paths := map[string][]string{}
+			for _, a := range c.Args() {
+				user, p := resolve(c.GlobalString("user"), a)
+				if _, ok := paths[user]; !ok {
+					users = append(users, user)
+				}
+				paths[user] = append(paths[user], p)
+			}
+			var results []dbx.RelocationResult
+			for _, user := range users {
+				d := newClient(user)
+				matches, err := d.RemovalPaths(paths[user])
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				if c.Bool("interactive") && !confirmRemoval(d, matches) {
+					continue
+				}
+				res, err := d.DeleteBatch(matches)
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				results = append(results, res...)
+			}
+			if dbx.PrintRelocations(results) > 0 {
+				os.Exit(1)
+			}
+		},
+	},
+	{
+		Name: "xcopy",
+		Usage: "copy between accounts: xcopy <user>:<src> <user>:<dst>",
+		Action: func(c *cli.Context) {
+			if len(c.Args()) != 2 {
+				fmt.Println("usage: dbox xcopy <user>:<src> <user>:<dst>"); os.Exit(2)
+			}
+			setOutput(c)
+			srcUser, src := accountPath(c.GlobalString("user"), c.Args().Get(0))
+			dstUser, dst := accountPath(c.GlobalString("user"), c.Args().Get(1))
+			d := newClient(dstUser)
+			meta, err := dbx.CrossCopy(newClient(srcUser), src, d, dst)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			meta.User = d.User
+			dbx.PrintMeta(meta)
+		},
+	},
+	{
+		Name: "dupes",
+		Usage: "find duplicate files under [path] by content hash",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "all, a",
+				Usage: "look for duplicates across all the registered users",
+			},
+			cli.BoolFlag{
+				Name: "plan, p",
+				Usage: "print a json plan of the copies to keep and to delete, as does --json",
+			},
+		},
+		Action: func(c *cli.Context) {
+			setOutput(c)
+			user, path := resolve(c.GlobalString("user"), c.Args().First())
+			accounts := []string{newClient(user).User}
+			if c.Bool("all") {
+				accounts = Userlist()
+			}
+			groups, failed := dbx.FindDupes(accounts, path)
+			for _, r := range failed {
+				fmt.Printf("error: listing failed for user %s: %s\n", r.User, r.Err)
+			}
+			// --json has printed the plan since before --output
+			if c.Bool("plan") || c.GlobalBool("json") {
+				dbx.PrintPlan(groups)
+			} else {
+				dbx.PrintDupes(groups)
+			}
+			if len(failed) > 0 {
+				os.Exit(1)
+			}
+		},
+	},
+	{
+		Name: "du",
+		Usage: "show the cumulative size and file count of the folders under [path]",
+		Flags: []cli.Flag{
+			cli.IntFlag{
+				Name: "depth, d",
+				Value: 1,
+				Usage: "folder depth to report, 0 for all",
+			},
+			cli.BoolFlag{
+				Name: "bytes, b",
+				Usage: "print sizes in bytes instead of human readable units",
+			},
+			cli.BoolFlag{
+				Name: "interactive, i",
+				Usage: "browse the folders interactively",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			root, err := d.DiskUsage(path)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			if c.Bool("interactive") {
+				dbx.BrowseUsage(root, os.Stdin, !c.Bool("bytes"))
+			} else {
+				depth := c.Int("depth")
+				if depth > 0 {
+					depth += 1 // the root folder is level 0
+				}
+				dbx.PrintUsageTree(root, depth, !c.Bool("bytes"))
+			}
+		},
+	},
+	{
+		Name: "find",
+		Usage: "search the entries under [path] offline, in the local metadata cache",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "regex, e",
+				Value: "",
+				Usage: "regular expression matched against the full path, e.g. '(?i)\\.flac$'",
+			},
+			cli.StringFlag{
+				Name: "type, t",
+				Value: "",
+				Usage: "file or folder",
+			},
+			cli.StringFlag{
+				Name: "min_size",
+				Value: "",
+				Usage: "minimum file size in bytes, or with a K, M or G suffix",
+			},
+			cli.StringFlag{
+				Name: "max_size",
+				Value: "",
+				Usage: "maximum file size in bytes, or with a K, M or G suffix",
+			},
+			cli.StringFlag{
+				Name: "newer",
+				Value: "",
+				Usage: "modified on or after this date, e.g. 2018-03-01",
+			},
+			cli.StringFlag{
+				Name: "older",
+				Value: "",
+				Usage: "modified before this date",
+			},
+		},
+		Action: func(c *cli.Context) {
+			setOutput(c)
+			user, path := resolve(c.GlobalString("user"), c.Args().First())
+			d := newClient(user)
+			useCache(c, d)
+			opts := dbx.FindOptions{
+				Type: c.String("type"),
+				MinSize: parseSize(c.String("min_size")),
+				MaxSize: parseSize(c.String("max_size")),
+				Newer: c.String("newer"),
+				Older: c.String("older"),
+			}
+			if c.String("regex") != "" {
+				re, err := regexp.Compile(c.String("regex"))
+				if err != nil {fmt.Println("error:", err); os.Exit(2)}
+				opts.Regex = re
+			}
+			dbx.PrintFound(d.Cache.Find(path, opts), d.User)
+		},
+	},
+	{
+		Name: "playlist",
+		Usage: "write a playlist of temporary links to the media files under <path>: playlist <path> [<local file>]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "format, f",
+				Value: "m3u8",
+				Usage: fmt.Sprintf("playlist format, one of %s", strings.Join(dbx.PlaylistFormats, ", ")),
+			},
+			cli.BoolFlag{
+				Name: "recursive, r",
+				Usage: "include the files in sub-folders",
+			},
+			cli.StringFlag{
+				Name: "sort, s",
+				Value: "path",
+				Usage: fmt.Sprintf("track order: path or one of %s", strings.Join(dbx.SortKeys, ", ")),
+			},
+			cli.BoolFlag{
+				Name: "reverse, R",
+				Usage: "reverse the track order",
+			},
+			cli.BoolFlag{
+				Name: "shuffle",
+				Usage: "shuffle the tracks",
+			},
+			cli.StringFlag{
+				Name: "server",
+				Value: "",
+				Usage: "base URL of a running serve-stream, e.g. http://127.0.0.1:8080 (with ?token=<token> if it has one), for links that do not expire",
+			},
+			cli.BoolFlag{
+				Name: "tags, t",
+				Usage: "title the tracks and give their durations from the audio tags",
+			},
+		},
+		Action: func(c *cli.Context) {
+			checkChoice("playlist format", c.String("format"), dbx.PlaylistFormats)
+			checkChoice("sort key", c.String("sort"), append([]string{"path"}, dbx.SortKeys...))
+			d, path := client(c, 0)
+			opts := dbx.PlaylistOptions{
+				Recursive: c.Bool("recursive"),
+				SortBy: c.String("sort"),
+				Reverse: c.Bool("reverse"),
+				Shuffle: c.Bool("shuffle"),
+				Server: c.String("server"),
+				Tags: c.Bool("tags"),
+			}
+			pl, err := d.Playlist(path, opts)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			if len(pl.Tracks) == 0 {
+				fmt.Println("no media files found in", path)
+				os.Exit(1)
+			}
+			out := os.Stdout
+			if c.Args().Get(1) != "" {
+				out, err = os.Create(c.Args().Get(1))
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				defer out.Close()
+			}
+			if err = dbx.WritePlaylist(out, pl, c.String("format")); err != nil {
+				fmt.Println(err); os.Exit(2)
+			}
+			if out != os.Stdout {
+				fmt.Printf("%d track(s) written to %s\n", len(pl.Tracks), c.Args().Get(1))
+			}
+		},
+	},
+	{
+		Name: "thumbs",
+		Usage: "export the thumbnails of the images in <path>: thumbs <path> [<local folder>]",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "size, s",
+				Value: "w256h256",
+				Usage: fmt.Sprintf("thumbnail size, one of %s", strings.Join(dbx.ThumbnailSizes, ", ")),
+			},
+			cli.StringFlag{
+				Name: "format, f",
+				Value: "jpeg",
+				Usage: fmt.Sprintf("thumbnail format, one of %s", strings.Join(dbx.ThumbnailFormats, ", ")),
+			},
+			cli.StringFlag{
+				Name: "mode, m",
+				Value: "strict",
+				Usage: fmt.Sprintf("resizing mode, one of %s", strings.Join(dbx.ThumbnailModes, ", ")),
+			},
+			cli.BoolFlag{
+				Name: "recursive, r",
+				Usage: "include the images in sub-folders",
+			},
+			cli.StringFlag{
+				Name: "sheet",
+				Value: "",
+				Usage: "also write a contact sheet of all the thumbnails to this JPEG file",
+			},
+			cli.IntFlag{
+				Name: "columns, c",
+				Value: 8,
+				Usage: "use with --sheet: number of thumbnails per row",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			opts := dbx.ThumbnailOptions{Size: c.String("size"), Format: c.String("format"), Mode: c.String("mode")}
+			checkChoice("thumbnail size", opts.Size, dbx.ThumbnailSizes)
+			checkChoice("thumbnail format", opts.Format, dbx.ThumbnailFormats)
+			checkChoice("thumbnail mode", opts.Mode, dbx.ThumbnailModes)
+			localDir := c.Args().Get(1)
+			if localDir == "" {
+				localDir = pth.Base(path) + "_thumbs"
+			}
+			thumbs, err := d.ExportThumbnails(path, localDir, c.Bool("recursive"), opts)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			fmt.Printf("%d thumbnail(s) in %s\n", len(thumbs), localDir)
+			if c.String("sheet") != "" {
+				w, h := opts.Dimensions()
+				if err = dbx.ContactSheet(thumbs, w, h, c.Int("columns"), c.String("sheet")); err != nil {
+					fmt.Println(err); os.Exit(1)
+				}
+				fmt.Println("contact sheet:", c.String("sheet"))
+			}
+		},
+	},
+	{
+		Name: "preview",
+		Usage: "save a PDF or HTML preview of the document at <path>: preview <path> [<local file>]",
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			data, ctype, err := d.Preview(path)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			localPath := c.Args().Get(1)
+			if localPath == "" {
+				ext := ".pdf"
+				if strings.HasPrefix(ctype, "text/html") {
+					ext = ".html"
+				}
+				localPath = strings.TrimSuffix(pth.Base(path), pth.Ext(path)) + ext
+			}
+			if err = ioutil.WriteFile(localPath, data, 0666); err != nil {
+				fmt.Println(err); os.Exit(1)
+			}
+			fmt.Println("preview saved to", localPath)
+		},
+	},
+	{
+		Name: "media",
+		Usage: "report the resolution, duration, time taken and location of the photos and videos in [path]",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "recursive, r",
+				Usage: "include the files in sub-folders",
+			},
+			cli.StringFlag{
+				Name: "sort, s",
+				Value: "taken",
+				Usage: fmt.Sprintf("sort key, one of %s", strings.Join(dbx.MediaSortKeys, ", ")),
+			},
+			cli.BoolFlag{
+				Name: "reverse, R",
+				Usage: "reverse the sort order",
+			},
+			cli.BoolFlag{
+				Name: "by_date, g",
+				Usage: "group the files by capture date",
+			},
+			cli.IntFlag{
+				Name: "lookups, l",
+				Value: 500,
+				Usage: "maximum number of files to ask the media info of one by one, -1 for all",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 0)
+			checkChoice("sort key", c.String("sort"), dbx.MediaSortKeys)
+			media, err := d.ListMedia(path, c.Bool("recursive"), c.Int("lookups"))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			for k, _ := range media {
+				media.SetUser(d.User, k)
+			}
+			dbx.SortMedia(media, c.String("sort"), c.Bool("reverse"))
+			dbx.PrintMedia(media, c.Bool("by_date"))
+		},
+	},
+	{
+		Name: "fetch",
+		Usage: "save a file from the web into Dropbox: fetch <url> <remote path>, or fetch --batch <file> <remote folder>",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "batch, b",
+				Value: "",
+				Usage: "file of urls, one per line optionally followed by a file name, - for stdin",
+			},
+		},
+		Action: func(c *cli.Context) {
+			if c.String("batch") == "" {
+				d, path := client(c, 1)
+				rawurl := c.Args().First()
+				if rawurl == "" || c.Args().Get(1) == "" {
+					fmt.Println("error: usage: fetch <url> <remote path>"); os.Exit(2)
+				}
+				if strings.HasSuffix(path, "/") {
+					path += dbx.URLName(rawurl)
+				}
+				meta, err := d.FetchURL(rawurl, path, true)
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				dbx.PrintUploaded(meta)
+				return
+			}
+			d, folder := client(c, 0)
+			in := os.Stdin
+			if c.String("batch") != "-" {
+				f, err := os.Open(c.String("batch"))
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				defer f.Close()
+				in = f
+			}
+			jobs, err := dbx.ReadFetchList(in, folder)
+			if err != nil {fmt.Println(err); os.Exit(2)}
+			failed := 0
+			for _, j := range d.FetchAll(jobs) {
+				if j.Err != nil {
+					failed += 1
+				}
+			}
+			fmt.Fprintf(os.Stderr, "%d of %d url(s) fetched\n", len(jobs)-failed, len(jobs))
+			if failed > 0 {
+				os.Exit(1)
+			}
+		},
+	},
+	{
+		Name: "put",
+		Usage: "upload a file, or stdin with -, to <remote path>: put <local file|-> <remote path>",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name: "overwrite, o",
+				Usage: "replace the file at <remote path> if there is one",
+			},
+			cli.BoolFlag{
+				Name: "autorename, a",
+				Usage: "save under another name if there is a file at <remote path>, instead of failing",
+			},
+		},
+		Action: func(c *cli.Context) {
+			d, path := client(c, 1)
+			source := c.Args().First()
+			if source == "" || c.Args().Get(1) == "" {
+				fmt.Println("error: usage: put <local file|-> <remote path>"); os.Exit(2)
+			}
+			mode := "add"
+			if c.Bool("overwrite") {
+				mode = "overwrite"
+			}
+			if source == "-" {
+				uploadStdin(d, path, mode, c.Bool("autorename"))
+				return
+			}
+			f, err := os.Open(source)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			defer f.Close()
+			if strings.HasSuffix(path, "/") {
+				path += pth.Base(source)
+			}
+			meta, err := d.UploadStream(f, path, mode, c.Bool("autorename"))
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			dbx.PrintUploaded(meta)
+		},
+	},
+	{
+		Name: "cat",
+		Usage: "write the content of the file(s) at <path> [<path>...] to stdout",
+		Action: func(c *cli.Context) {
+			if len(c.Args()) == 0 {
+				fmt.Fprintln(os.Stderr, "error: missing path"); os.Exit(2)
+			}
+			for k, _ := range c.Args() {
+				d, path := client(c, k)
+				if _, err := d.Cat(path, os.Stdout); err != nil {
+					// stdout is for the data
+					fmt.Fprintln(os.Stderr, err); os.Exit(1)
+				}
+			}
+		},
+	},
+	{
+		Name: "serve-stream",
+		Usage: "serve the files of all the accounts at stable URLs, http://<addr>/<user>/<path>",
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name: "addr, a",
+				Value: "127.0.0.1:8080",
+				Usage: "address to listen on, only a loopback one without --token",
+			},
+			cli.StringFlag{
+				Name: "token",
+				Value: "",
+				Usage: "require this token in the token query parameter of the requests",
+			},
+		},
+		Action: func(c *cli.Context) {
+			if err := dbx.NewStreamServer(c.String("token")).Serve(c.String("addr")); err != nil {
+				fmt.Println(err); os.Exit(1)
+			}
+		},
+	},
+}
+
+// uploadStdin uploads stdin to the file at path, which has to be named
+// since stdin has no name of its own
+func uploadStdin(d *dbx.Client, path, mode string, autorename bool) {
+	if path == "" || strings.HasSuffix(path, "/") {
+		fmt.Println("error: stdin has no file name, give the full remote path of the file"); os.Exit(2)
+	}
+	meta, err := d.UploadStream(os.Stdin, path, mode, autorename)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	dbx.PrintUploaded(meta)
+}
+
+// checkChoice exits if v is not one of valid
+func checkChoice(name, v string, valid []string) {
+	if !utils.StringInSlice(v, valid) {
+		fmt.Printf("error: unknown %s %q, use one of %s\n", name, v, strings.Join(valid, ", "))
+		os.Exit(2)
+	}
+}
+
+// parseSize parses a size in bytes with an optional K, M or G (binary) suffix
+func parseSize(s string) int64 {
+	if s == "" {
+		return 0
+	}
+	mult := int64(1)
+	switch strings.ToUpper(s[len(s)-1:]) {
+	case "K":
+		mult = 1024
+	case "M":
+		mult = 1024*1024
+	case "G":
+		mult = 1024*1024*1024
+	}
+	if mult > 1 {
+		s = s[:len(s)-1]
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		fmt.Printf("error: invalid size %q\n", s); os.Exit(2)
+	}
+	return int64(n * float64(mult))
+}
+
+// accountPath splits a "<user>:<path>" argument, defaulting to user. The
+// prefix is only taken for a registered user name or uid, so that other
+// paths may contain a colon.
+func accountPath(user, arg string) (string, string) {
+	if i := strings.Index(arg, ":"); i > 0 {
+		prefix := arg[:i]
+		if utils.StringInSlice(prefix, Userlist()) {
+			user, arg = prefix, arg[i+1:]
+		} else if utils.StringInSlice(prefix, Uids()) {
+			var err error
+			user, err = UidToUser(prefix)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			arg = arg[i+1:]
+		}
+	}
+	return resolve(user, arg)
+}
+
+var relocationFlags = []cli.Flag{
+	cli.BoolFlag{
+		Name: "autorename, R",
+		Usage: "rename the destination item(s) on conflict",
+	},
+	cli.BoolFlag{
+		Name: "allow_ownership_transfer, O",
+		Usage: "allow moves/copies that transfer the ownership of the content",
+	},
+}
+
+func relocationOptions(c *cli.Context) dbx.RelocationOptions {
+	return dbx.RelocationOptions{
+		Autorename: c.Bool("autorename"),
+		AllowOwnershipTransfer: c.Bool("allow_ownership_transfer"),
+	}
+}
+
+// relocationArgs returns a client and the source(s) and destination paths
+// of a copy or move command: <src> [<src>...] <dst>
+func relocationArgs(c *cli.Context, name string) (*dbx.Client, []string, string) {
+	args := c.Args()
+	if len(args) < 2 {
+		fmt.Printf("usage: dbox %s <src> [<src>...] <dst>\n", name); os.Exit(2)
+	}
+	setOutput(c)
+	user, dst := resolve(c.GlobalString("user"), args[len(args)-1])
+	var sources []string
+	for _, a := range args[:len(args)-1] {
+		srcUser, src := resolve(user, a)
+		if srcUser != user {
+			fmt.Printf("error: %s is not in the account of %s, use xcopy to copy between accounts\n", a, args[len(args)-1]); os.Exit(2)
+		}
+		sources = append(sources, src)
+	}
+	return newClient(user), sources, dst
+}
+
+// confirmRemoval shows what removing paths would delete and asks whether
+// to go on
+func confirmRemoval(d *dbx.Client, paths []string) bool {
+	var files int
+	var size int64
+	for _, p := range paths {
+		n, bytes, err := d.RemovalSummary(p)
+		if err != nil {fmt.Println(err); os.Exit(1)}
+		nsize, _ := utils.NiceBytes(bytes)
+		fmt.Printf("%s: %d file(s), %s\n", p, n, nsize)
+		files += n
+		size += bytes
+	}
+	nsize, _ := utils.NiceBytes(size)
+	return confirm(fmt.Sprintf("remove these %d item(s) from %s: %d file(s), %s?", len(paths), d.User, files, nsize))
+}
+
+// confirm asks a yes/no question on the terminal, defaulting to no
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
+
+// client returns a client for the current/specified user, or for the user
+// prefixing the n-th argument, and the remote path given by that argument
+func client(c *cli.Context, n int) (*dbx.Client, string) {
+	setOutput(c)
+	setTypes(c)
+	user, path := resolve(c.GlobalString("user"), c.Args().Get(n))
+	d := newClient(user)
+	if c.GlobalBool("cached") || c.GlobalBool("refresh") {
+		useCache(c, d)
+	}
+	return d, path
+}
+
+// sharedFolder returns a client and the shared folder id designated by the
+// first argument, which may be an id or the path of a mounted folder
+func sharedFolder(c *cli.Context) (*dbx.Client, string) {
+	setOutput(c)
+	arg := c.Args().First()
+	if arg == "" {
+		fmt.Println("error: missing shared folder path or id"); os.Exit(2)
+	}
+	user := c.GlobalString("user")
+	if strings.Contains(arg, "/") {
+		user, arg = resolve(user, arg)
+	}
+	d := newClient(user)
+	id, err := d.SharedFolderId(arg)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	return d, id
+}
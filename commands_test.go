@@ -0,0 +1,49 @@
+package main
+
+import(
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+			s    string
+			want int64
+	}{
+		{"", 0},
+		{"0", 0},
+		{"512", 512},
+		{"10k", 10*1024},
+		{"1.5M", 1536*1024},
+		{"2G", 2*1024*1024*1024},
+		{"2g", 2*1024*1024*1024},
+	}
+	for _, tt := range tests {
+		if got := parseSize(tt.s); got != tt.want {
+			t.Errorf("parseSize(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+			s    string
+			want []string
+	}{
+		{"", nil},
+		{"audio", []string{"audio"}},
+		{" audio, video ,,image", []string{"audio", "video", "image"}},
+	}
+	for _, tt := range tests {
+		got := splitList(tt.s)
+		if len(got) != len(tt.want) {
+			t.Errorf("splitList(%q) = %q, want %q", tt.s, got, tt.want)
+			continue
+		}
+		for k := range got {
+			if got[k] != tt.want[k] {
+				t.Errorf("splitList(%q) = %q, want %q", tt.s, got, tt.want)
+				break
+			}
+		}
+	}
+}
@@ -44,9 +44,123 @@ func UidToUser(u string) (string, error) {
 }
 
 
+// resolve checks the user and strips an optional user name or uid prefix
+// from a remote path argument, returning the user and the absolute path
+func resolve(user, path string) (string, string) {
+	if user != "current_user" {
+		if _, ok := users[user]; !ok {
+			fmt.Printf("error: %q is not a registered user\n", user)
+			fmt.Println("use one of", strings.Join(Userlist(), ", "))
+			os.Exit(2)
+		}
+	}		
+	if utils.StringInSlice(strings.Split(path, "/")[0], Userlist()) {
+		user = strings.Split(path, "/")[0]
+		path = strings.Join(strings.Split(path, "/")[1:], "/")
+	} else if utils.StringInSlice(strings.Split(path, "/")[0], Uids()) {
+		var err error
+		user, err = UidToUser(strings.Split(path, "/")[0])
+		if err != nil {
+			fmt.Println(err); os.Exit(1)
+		}
+		path = strings.Join(strings.Split(path, "/")[1:], "/")			
+	}
+	if path != "" && path[:1] != "/" {path = "/" + path}
+	return user, path
+}
+
+// removeGlob deletes the entries matching pattern in a single batch
+func removeGlob(d *dbx.Client, pattern string, interactive bool) {
+	entries, err := d.Glob(pattern)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	if len(entries) == 0 {
+		fmt.Println("no match for", pattern)
+		return
+	}
+	var paths []string
+	for _, e := range entries {
+		if interactive {
+			fmt.Println(e.PathDisplay)
+		}
+		paths = append(paths, e.PathDisplay)
+	}
+	if interactive && !confirm(fmt.Sprintf("remove these %d item(s)?", len(paths))) {
+		return
+	}
+	results, err := d.DeleteBatch(paths)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	if dbx.PrintRelocations(results) > 0 {
+		os.Exit(1)
+	}
+}
+
+// useCache loads the metadata cache of the client's user, syncing it if
+// it is empty or on --refresh
+func useCache(c *cli.Context, d *dbx.Client) {
+	k, err := dbx.LoadCache(d.User)
+	if err != nil {fmt.Println("error: could not read metadata cache:", err); os.Exit(1)}
+	if k.Empty() || c.GlobalBool("refresh") {
+		if err = d.SyncCache(k); err != nil {fmt.Println(err); os.Exit(1)}
+		if err = k.Save(); err != nil {fmt.Println("error: could not save metadata cache:", err); os.Exit(1)}
+	}
+	d.Cache = k
+}
+
+// setOutput sets the output format from the global flags
+func setOutput(c *cli.Context) {
+	dbx.Output = c.GlobalString("output")
+	if c.GlobalBool("json") {
+		dbx.Output = "json"
+	}
+	dbx.Template = c.GlobalString("template")
+	if !utils.StringInSlice(dbx.Output, dbx.Formats) {
+		fmt.Printf("error: unknown output format %q, use one of %s\n", dbx.Output, strings.Join(dbx.Formats, ", "))
+		os.Exit(2)
+	}
+}
+
+// setTypes sets the file kinds kept by links, play, download and search
+func setTypes(c *cli.Context) {
+	dbx.TypeFilter = splitList(c.GlobalString("type"))
+	dbx.Sniff = c.GlobalBool("sniff")
+	if err := dbx.CheckTypes(dbx.TypeFilter); err != nil {
+		fmt.Println(err); os.Exit(2)
+	}
+}
+
+func listOptions(c *cli.Context) dbx.ListOptions {
+	if !utils.StringInSlice(c.String("sort"), dbx.SortKeys) {
+		fmt.Printf("error: unknown sort key %q, use one of %s\n", c.String("sort"), strings.Join(dbx.SortKeys, ", "))
+		os.Exit(2)
+	}
+	return dbx.ListOptions{
+		Long: c.Bool("long"),
+		SortBy: c.String("sort"),
+		Reverse: c.Bool("reverse"),
+		FoldersFirst: c.Bool("folders_first"),
+		Bytes: c.Bool("bytes"),
+		Tags: c.Bool("tags"),
+	}
+}
+
+// splitList splits a comma separated flag value
+func splitList(s string) (l []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return
+}
+
+func newClient(user string) *dbx.Client {
+	d := dbx.NewClient(api_url, cfg_file, "", dbx.Auth{}, map[string]string{})
+	d.SetToken(user)
+	return d
+}
+
 func main() {
 	userlist := Userlist()
-	uids := Uids()
 	app := cli.NewApp()
 	app.Name = "dropbox"
 	app.Version = "0.50"
@@ -62,6 +176,11 @@ func main() {
 		Name: "info, i",
 		Usage: "get account info for the current/specified user",
 		},
+	cli.Float64Flag{
+		Name: "threshold, T",
+		Value: 0,
+		Usage: "use with --info --all_users to exit with status 2 when an account is more than <x> percent full",
+		},
 	cli.BoolFlag{
 		Name: "meta, M",
 		Usage: "get metadata for the specified path",
@@ -72,19 +191,41 @@ func main() {
 		},
 	cli.BoolFlag{
 		Name: "all_users, a",
-		Usage: "list the specified path for all users",
+		Usage: "list/search the specified path or get account info for all users",
+		},
+	cli.IntFlag{
+		Name: "jobs, j",
+		Value: 4,
+		Usage: "number of accounts processed concurrently with --all_users",
 		},
 	cli.BoolFlag{
 		Name: "link, k",
-		Usage: "get streamable link(s) for item(s) under the specified path",
+		Usage: "get streamable link(s) for item(s) under the specified path or matching the specified glob pattern",
 		},
 	cli.BoolFlag{
 		Name: "play, S",
-		Usage: "stream link(s) for item(s) under the specified path in foobar2000 or VLC",
+		Usage: "stream link(s) for item(s) under the specified path in a media player",
+		},
+	cli.StringFlag{
+		Name: "player",
+		Value: "",
+		Usage: "use with --play to prefer this player (mpv, vlc, foobar2000, mplayer or one from the config file)",
+		},
+	cli.BoolFlag{
+		Name: "enqueue, Q",
+		Usage: "use with --play to add to the player's playlist instead of replacing it",
 		},
 	cli.BoolFlag{
 		Name: "download, d",
-		Usage: "download file(s) under the specified path",
+		Usage: "download file(s) under the specified path or matching the specified glob pattern",
+		},
+	cli.BoolFlag{
+		Name: "zip, z",
+		Usage: "use with --download to get a folder as a single zip archive, extracted on the fly",
+		},
+	cli.BoolFlag{
+		Name: "save_zip",
+		Usage: "use with --download to save a folder as a zip archive",
 		},
 	cli.IntFlag{
 		Name: "depth, r",
@@ -117,12 +258,12 @@ func main() {
 	cli.StringFlag{
 		Name: "upload, p",
 		Value: "",
-		Usage: "upload file(s) to the specified parent folder path",
+		Usage: "upload file(s) to the specified parent folder path, or with -, stdin to the specified file path",
 		},
 	cli.StringFlag{
 		Name: "chunked_upload, cu",
 		Value: "",
-		Usage: "upload large file(s) by chunks to the specified parent folder path",
+		Usage: "upload large file(s) by chunks to the specified parent folder path, or with -, stdin to the specified file path",
 		},
 	cli.IntFlag{
 		Name: "chunk_size, cs",
@@ -139,9 +280,92 @@ func main() {
 		Value: "",
 		Usage: "search for the specified <query> string",
 		},
+	cli.StringFlag{
+		Name: "ext, e",
+		Value: "",
+		Usage: "use with --search to match comma separated file extensions only",
+		},
+	cli.StringFlag{
+		Name: "type, y",
+		Value: "",
+		Usage: "use with --link, --play, --download or --search to keep comma separated file types only (audio, video, image, document...)",
+		},
+	cli.BoolFlag{
+		Name: "sniff",
+		Usage: "use with --type to detect the type of files with an unknown extension from their content",
+		},
+	cli.StringFlag{
+		Name: "category, g",
+		Value: "",
+		Usage: "use with --search to match comma separated file categories only (image, document, pdf, audio, video, folder...)",
+		},
+	cli.BoolFlag{
+		Name: "filename_only, F",
+		Usage: "use with --search to match file names only, not contents",
+		},
+	cli.IntFlag{
+		Name: "max_results, N",
+		Value: 0,
+		Usage: "use with --search to limit the number of results",
+		},
+	cli.StringFlag{
+		Name: "output, o",
+		Value: "text",
+		Usage: fmt.Sprintf("output format of listings, one of %s", strings.Join(dbx.Formats, ", ")),
+		},
+	cli.BoolFlag{
+		Name: "json, J",
+		Usage: "shorthand for --output json",
+		},
+	cli.StringFlag{
+		Name: "template",
+		Value: "",
+		Usage: "print each listed item with a go template instead, e.g. '{{.Path}} {{.Size}} {{.ContentHash}}'",
+		},
 	cli.BoolFlag{
 		Name: "remove, rm",
-		Usage: "remove item(s) at the specified path",
+		Usage: "remove item(s) at the specified path or matching the specified glob pattern",
+		},
+	cli.BoolFlag{
+		Name: "deleted, D",
+		Usage: "include deleted entries when listing the specified path",
+		},
+	cli.BoolFlag{
+		Name: "long, l",
+		Usage: "long listing format with type, size, modification time, rev and content hash",
+		},
+	cli.StringFlag{
+		Name: "sort",
+		Value: "name",
+		Usage: fmt.Sprintf("sort listings by one of %s", strings.Join(dbx.SortKeys, ", ")),
+		},
+	cli.BoolFlag{
+		Name: "reverse, R",
+		Usage: "reverse the listing order",
+		},
+	cli.BoolFlag{
+		Name: "folders_first, ff",
+		Usage: "list folders before files",
+		},
+	cli.BoolFlag{
+		Name: "bytes, b",
+		Usage: "print sizes in bytes",
+		},
+	cli.BoolFlag{
+		Name: "tags",
+		Usage: "list the artist, title and duration of audio files, read from their tags",
+		},
+	cli.BoolFlag{
+		Name: "cached, K",
+		Usage: "answer listings, tree and du from the local metadata cache",
+		},
+	cli.BoolFlag{
+		Name: "refresh",
+		Usage: "sync the local metadata cache with the server first (implies --cached)",
+		},
+	cli.BoolFlag{
+		Name: "interactive, I",
+		Usage: "show what would be lost and ask for confirmation before removing",
 		},
 	}
 	app.Action = func(c *cli.Context) {
@@ -150,52 +374,81 @@ func main() {
 		if len(c.Args()) > 0 {
 			path = c.Args()[0]
 		}		
-		if user != "current_user" {
-			if _, ok := users[user]; !ok {
-				fmt.Printf("error: %q is not a registered user\n", user)
-				fmt.Println("use one of", strings.Join(userlist, ", "))
-				os.Exit(2)
-			}
-		}		
-		if utils.StringInSlice(strings.Split(path, "/")[0], userlist) {
-			user = strings.Split(path, "/")[0]
-			path = strings.Join(strings.Split(path, "/")[1:], "/")
-		} else if utils.StringInSlice(strings.Split(path, "/")[0], uids) {
-			var err error
-			user, err = UidToUser(strings.Split(path, "/")[0])
-			if err != nil {
-				fmt.Println(err); os.Exit(1)
-			}
-			path = strings.Join(strings.Split(path, "/")[1:], "/")			
-		}
-		if path != "" && path[:1] != "/" {path = "/" + path}
+		user, path = resolve(user, path)
 				
+		setOutput(c)
+		setTypes(c)
+		dbx.Parallelism = c.Int("jobs")
 		d := dbx.NewClient(api_url, cfg_file, "", dbx.Auth{}, map[string]string{})
 		if !c.Bool("all") { d.SetToken(user) }
+		if c.Bool("cached") || c.Bool("refresh") {
+			useCache(c, d)
+		}
 		switch {
 		case c.Bool("tree"):
 			depth := c.Int("depth")
 			if depth == 1 {depth = 0}
 			d.GetTree(path, depth, 0)
 		case c.Bool("info"):
-			d.Info()
+			if !c.Bool("all_users") {
+				d.Info()
+				break
+			}
+			usages := dbx.UsageAll(Userlist())
+			dbx.PrintUsage(usages)
+			status := 0
+			for _, u := range usages {
+				if u.Error != "" {
+					status = 1
+				} else if c.Float64("threshold") > 0 && u.Percent >= c.Float64("threshold") && status == 0 {
+					status = 2
+				}
+			}
+			os.Exit(status)
 		case c.Bool("download"):
+			if dbx.HasMeta(path) {
+				d.DownloadGlob(path, c.Bool("aria"), c.Bool("fast"), c.Int("depth"), c.Int("parallel"), c.Int("conns"))
+				break
+			}
 			localPath := pth.Base(path)
+			if c.Bool("zip") || c.Bool("save_zip") {
+				err := d.DownloadZip(path, localPath, !c.Bool("save_zip"))
+				if err == nil {
+					break
+				}
+				// a failed extraction is completed file by file
+				if err != dbx.ErrZipUnsuitable && c.Bool("save_zip") {
+					fmt.Println(err); os.Exit(1)
+				}
+				fmt.Printf("%s: %s, downloading file by file\n", path, err)
+			}
 			d.Download(path, localPath, c.Bool("aria"), c.Bool("fast"), c.Int("depth"), c.Int("parallel"), c.Int("conns"))
 		case c.Bool("link"):
 			stream := false
 			d.GetLinks(path, stream) 
 		case c.Bool("play"):
-			d.StreamLinks(path)
+			d.StreamLinks(path, c.String("player"), c.Bool("enqueue"))
 		case c.String("search") != "" :
 			query := c.String("search")
+			opts := dbx.SearchOptions{
+				Path: path,
+				MaxResults: c.Int("max_results"),
+				FilenameOnly: c.Bool("filename_only"),
+				Extensions: splitList(c.String("ext")),
+				Categories: splitList(c.String("category")),
+			}
 			if c.Bool("all_users") {
-				d.SearchAll(path, query)
+				d.SearchAll(query, opts)
 			} else {
-				d.SearchUser(path, query)
+				d.SearchUser(query, opts)
 			}
 		case c.String("move") != "" :
 			d.Move(c.String("move"), path)
+		case c.String("upload") == "-" || c.String("chunked_upload") == "-":
+			if c.Int("chunk_size") > 0 {
+				dbx.Chunksize = int64(c.Int("chunk_size")*1024*1024)
+			}
+			uploadStdin(d, path, "add", false)
 		case c.String("upload") != "" :
 			d.Upload(c.String("upload"), path)
 		case c.String("chunked_upload") != "" :
@@ -210,17 +463,40 @@ func main() {
 				fmt.Println("error: cannot remove root folder")
 				os.Exit(2)			
 			} 
+			// what is removed is never taken from a possibly stale cache
+			d.Cache = nil
+			if dbx.HasMeta(path) {
+				removeGlob(d, path, c.Bool("interactive"))
+				break
+			}
+			if c.Bool("interactive") {
+				files, size, err := d.RemovalSummary(path)
+				if err != nil {fmt.Println(err); os.Exit(1)}
+				nsize, _ := utils.NiceBytes(size)
+				if !confirm(fmt.Sprintf("remove %s: %d file(s), %s?", path, files, nsize)) {
+					os.Exit(0)
+				}
+			}
 			d.Remove(path)
 		case c.Bool("meta"):
-			meta, _ := d.GetMetadata(path)
-			fmt.Printf("%+v\n", meta)
+			getMetadata := d.GetMetadata
+			if kind := dbx.MediaType(path); kind == "image" || kind == "video" {
+				getMetadata = d.GetMediaMetadata
+			}
+			meta, err := getMetadata(path)
+			if err != nil {fmt.Println(err); os.Exit(1)}
+			meta.User = d.User
+			dbx.PrintMeta(meta)
 		default:
 			if c.Bool("all_users") {
 				d.ListAll(path)
+			} else if c.Bool("deleted") {
+				d.ListFolderDeleted(path, listOptions(c))
 			} else {
-				d.ListFolder(path)
+				d.ListFolder(path, listOptions(c))
 			}
 		}
 	}
+	app.Commands = commands
   app.Run(os.Args)
 }		
@@ -0,0 +1,49 @@
+package dboxlib
+
+import(
+	"sort"
+	"sync"
+)
+
+// default number of accounts processed concurrently by the *All methods
+var Parallelism = 4
+
+type AccountResult struct {
+		User  string
+		Value interface{}
+		Err   error
+}
+
+// AccountClient returns a new client authenticated for user, independent
+// from any other client
+func AccountClient(user string) *Client {
+	c := NewClient(api_url, cfg_file, "", Auth{}, map[string]string{})
+	c.SetToken(user)
+	return c
+}
+
+// ForEachAccount runs op for every account with its own client, at most
+// limit at a time (all at once if limit <= 0), and returns the results
+// sorted by account name, whatever the order of completion
+func ForEachAccount(accounts []string, limit int, op func(c *Client) (interface{}, error)) []AccountResult {
+	sorted := append([]string(nil), accounts...)
+	sort.Strings(sorted)
+	if limit <= 0 {
+		limit = len(sorted)
+	}
+	results := make([]AccountResult, len(sorted))
+	sem := make(chan bool, limit)
+	var wg sync.WaitGroup
+	for i, user := range sorted {
+		wg.Add(1)
+		go func(i int, user string) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			v, err := op(AccountClient(user))
+			results[i] = AccountResult{user, v, err}
+		}(i, user)
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,177 @@
+package dboxlib
+
+import(
+	"os"
+	"fmt"
+	"sort"
+	"time"
+	"regexp"
+	"strings"
+	"io/ioutil"
+	"encoding/json"
+	pth "path"
+	ospath "path/filepath"
+	"github.com/xiconet/utils"
+)
+
+// folder of the per-account metadata cache files
+var CacheDir = cacheDir()
+
+func cacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return ospath.Join(dir, "dbox")
+}
+
+// Cache is an on-disk copy of the metadata of all the entries of an
+// account, kept up to date with a list_folder cursor
+type Cache struct {
+		User    string           `json:"user"`
+		Cursor  string           `json:"cursor"`
+		Synced  string           `json:"synced"`
+		Entries map[string]Entry `json:"entries"` // by path_lower
+}
+
+func cacheFile(user string) string {
+	return ospath.Join(CacheDir, user + ".json")
+}
+
+// LoadCache reads the cache of user, returning an empty one if there is none yet
+func LoadCache(user string) (*Cache, error) {
+	k := &Cache{User: user, Entries: map[string]Entry{}}
+	data, err := ioutil.ReadFile(cacheFile(user))
+	if os.IsNotExist(err) {
+		return k, nil
+	}
+	if err != nil {
+		return k, err
+	}
+	err = json.Unmarshal(data, k)
+	if k.Entries == nil {
+		k.Entries = map[string]Entry{}
+	}
+	return k, err
+}
+
+// Save writes the cache to disk, replacing the previous file atomically
+func (k *Cache) Save() error {
+	if err := os.MkdirAll(CacheDir, 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(k)
+	if err != nil {
+		return err
+	}
+	tmp := cacheFile(k.User) + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, cacheFile(k.User))
+}
+
+// Empty reports whether the cache has never been synced
+func (k *Cache) Empty() bool {
+	return k.Cursor == ""
+}
+
+func (k *Cache) apply(entries Entries) {
+	for _, e := range entries {
+		if e.Tag != "deleted" {
+			k.Entries[e.PathLower] = e
+			continue
+		}
+		delete(k.Entries, e.PathLower)
+		prefix := e.PathLower + "/"
+		for p, _ := range k.Entries {
+			if strings.HasPrefix(p, prefix) {
+				delete(k.Entries, p)
+			}
+		}
+	}
+}
+
+// SyncCache brings the cache up to date: a full recursive listing the first
+// time, then only the changes since the saved cursor
+func (c *Client) SyncCache(k *Cache) error {
+	cursor, err := c.listPages(ListFolderArg{Path: "", Recursive: true}, k.Cursor, k.apply)
+	if err != nil && k.Cursor != "" && HasErrorTag(err, "reset") {
+		// the cursor was invalidated, start over
+		k.Cursor, k.Entries = "", map[string]Entry{}
+		return c.SyncCache(k)
+	}
+	if err != nil {
+		return err
+	}
+	k.Cursor = cursor
+	k.Synced = time.Now().UTC().Format(time.RFC3339)
+	return nil
+}
+
+// List returns the cached entries in path, or below it if recursive,
+// as list_folder would
+func (k *Cache) List(path string, recursive bool) Entries {
+	var entries Entries
+	dir := strings.TrimSuffix(strings.ToLower(path), "/")
+	for p, e := range k.Entries {
+		parent := pth.Dir(p)
+		if parent == "/" {
+			parent = ""
+		}
+		switch {
+		case recursive && (p == dir || strings.HasPrefix(p, dir + "/")):
+		case !recursive && parent == dir:
+		default:
+			continue
+		}
+		entries = append(entries, e)
+	}
+	sort.Sort(ByPathLower(entries))
+	return entries
+}
+
+type FindOptions struct {
+		Regex   *regexp.Regexp // matched against the full path
+		Type    string         // file or folder, any if empty
+		MinSize int64
+		MaxSize int64          // no limit if <= 0
+		Newer   string         // modified at or after this date (e.g. 2018-03-01)
+		Older   string         // modified before this date
+}
+
+// Find returns the cached entries below path matching opts
+func (k *Cache) Find(path string, opts FindOptions) Entries {
+	var found Entries
+	for _, e := range k.List(path, true) {
+		switch {
+		case opts.Type != "" && e.Tag != opts.Type:
+		case opts.Regex != nil && !opts.Regex.MatchString(e.PathDisplay):
+		case e.Size < opts.MinSize:
+		case opts.MaxSize > 0 && e.Size > opts.MaxSize:
+		case opts.Newer != "" && (e.ServerModified == "" || e.ServerModified < opts.Newer):
+		case opts.Older != "" && (e.ServerModified == "" || e.ServerModified >= opts.Older):
+		default:
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func PrintFound(found Entries, user string) {
+	for k, _ := range found {
+		found.SetUser(user, k)
+	}
+	if Structured() {
+		Emit(entryRows(found))
+		return
+	}
+	for _, e := range found {
+		if e.Tag == "folder" {
+			fmt.Println(e.PathDisplay + "/")
+			continue
+		}
+		size, _ := utils.NiceBytes(e.Size)
+		fmt.Printf("%-70s %8s  %s\n", e.PathDisplay, size, e.ServerModified)
+	}
+}
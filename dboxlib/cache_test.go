@@ -0,0 +1,95 @@
+package dboxlib
+
+import(
+	"regexp"
+	"testing"
+)
+
+func testCache() *Cache {
+	k := &Cache{User: "u", Entries: map[string]Entry{}}
+	k.apply(Entries{
+		{Tag: "folder", PathLower: "/a", PathDisplay: "/A"},
+		{Tag: "file", PathLower: "/a/1.mp3", PathDisplay: "/A/1.mp3", Size: 100, ServerModified: "2018-01-10T00:00:00Z"},
+		{Tag: "folder", PathLower: "/a/sub", PathDisplay: "/A/sub"},
+		{Tag: "file", PathLower: "/a/sub/2.flac", PathDisplay: "/A/sub/2.flac", Size: 5000, ServerModified: "2018-03-05T00:00:00Z"},
+		{Tag: "folder", PathLower: "/ab", PathDisplay: "/ab"},
+		{Tag: "file", PathLower: "/ab/3.txt", PathDisplay: "/ab/3.txt", Size: 10, ServerModified: "2017-12-31T00:00:00Z"},
+		{Tag: "file", PathLower: "/top.txt", PathDisplay: "/top.txt", Size: 1},
+	})
+	return k
+}
+
+func paths(entries Entries) []string {
+	var p []string
+	for _, e := range entries {
+		p = append(p, e.PathLower)
+	}
+	return p
+}
+
+func samePaths(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestCacheList(t *testing.T) {
+	k := testCache()
+	tests := []struct {
+			path      string
+			recursive bool
+			want      []string
+	}{
+		{"", false, []string{"/a", "/ab", "/top.txt"}},
+		{"/A", false, []string{"/a/1.mp3", "/a/sub"}},
+		{"/a/", false, []string{"/a/1.mp3", "/a/sub"}},
+		{"/a", true, []string{"/a", "/a/1.mp3", "/a/sub", "/a/sub/2.flac"}},
+		{"/missing", true, nil},
+	}
+	for _, tt := range tests {
+		if got := paths(k.List(tt.path, tt.recursive)); !samePaths(got, tt.want) {
+			t.Errorf("List(%q, %v) = %q, want %q", tt.path, tt.recursive, got, tt.want)
+		}
+	}
+}
+
+func TestCacheApplyDeleted(t *testing.T) {
+	k := testCache()
+	k.apply(Entries{
+		{Tag: "deleted", PathLower: "/a"},
+		{Tag: "file", PathLower: "/top.txt", PathDisplay: "/top.txt", Size: 2},
+	})
+	if got, want := paths(k.List("", true)), []string{"/ab", "/ab/3.txt", "/top.txt"}; !samePaths(got, want) {
+		t.Errorf("after deleting /a: %q, want %q", got, want)
+	}
+	if k.Entries["/top.txt"].Size != 2 {
+		t.Errorf("modified entry not updated: %+v", k.Entries["/top.txt"])
+	}
+}
+
+func TestCacheFind(t *testing.T) {
+	k := testCache()
+	tests := []struct {
+			path string
+			opts FindOptions
+			want []string
+	}{
+		{"", FindOptions{Type: "folder"}, []string{"/a", "/a/sub", "/ab"}},
+		{"", FindOptions{Regex: regexp.MustCompile(`\.(mp3|flac)$`)}, []string{"/a/1.mp3", "/a/sub/2.flac"}},
+		{"", FindOptions{Type: "file", MinSize: 50, MaxSize: 1000}, []string{"/a/1.mp3"}},
+		{"", FindOptions{Newer: "2018-01-01"}, []string{"/a/1.mp3", "/a/sub/2.flac"}},
+		{"", FindOptions{Type: "file", Older: "2018-01-01"}, []string{"/ab/3.txt"}},
+		{"/a/sub", FindOptions{Type: "file"}, []string{"/a/sub/2.flac"}},
+	}
+	for _, tt := range tests {
+		if got := paths(k.Find(tt.path, tt.opts)); !samePaths(got, tt.want) {
+			t.Errorf("Find(%q, %+v) = %q, want %q", tt.path, tt.opts, got, tt.want)
+		}
+	}
+}
@@ -35,9 +35,8 @@ const(
 
 var(
 	users = map[string]string{"user0": "0", "user1": "1", ..., "usern": "n"} // FIXME
-	audioTypes = []string{".mp3", ".flac", ".ape", ".wav", ".wv", ".mpc", ".ogg", ".m4a"}
-	unhandled = []string{".ape", ".wv", ".wav"} // unhandled by foobar2000 but VLC is OK
 	Chunksize = int64(8*1024*1024)
+	JobPollInterval = time.Second
 )
 
 func Userlist() (u []string) {
@@ -105,6 +104,10 @@ type Entry struct {
 		PathDisplay string `json:"path_display"`
 		Id string `json:"id"`
 		Size int64 `json:size"`
+		Rev string `json:"rev,omitempty"`
+		ContentHash string `json:"content_hash,omitempty"`
+		ServerModified string `json:"server_modified,omitempty"`
+		MediaInfo *MediaInfo `json:"media_info,omitempty"`
 		User string // to be set later on
 }
 
@@ -137,9 +140,10 @@ type Meta struct {
 		ServerModified string	`json:"server_modified"`		
 		Size int64				`json:"size"`
 		Tag string 				`json:".tag"`
+		MediaInfo *MediaInfo    `json:"media_info,omitempty"`
 		ErrorSummary string     `json:"error_summary,omitempty"`
 		Error DbxError          `json:"error,omitempty"`		
-		User string				// to be set later on 
+		User string				`json:"user,omitempty"` // to be set later on 
 }
 
 type DbxError struct  {
@@ -225,6 +229,7 @@ type Client struct {
 		User string
 		Auth Auth
 		Endpoints map[string]string		
+		Cache *Cache // answer listings from a local metadata cache if set
 }
 
 type Auth struct {
@@ -233,7 +238,7 @@ type Auth struct {
 }
 
 func NewClient(baseUrl, cfgFile, user string, auth Auth, endpoints map[string]string) (c *Client){
-	return &Client{baseUrl, cfgFile, user, Auth{}, map[string]string{}}
+	return &Client{baseUrl, cfgFile, user, Auth{}, map[string]string{}, nil}
 }
 
 func (c *Client) SetToken(user string) string {
@@ -251,28 +256,23 @@ func (c *Client) SetToken(user string) string {
 	return token	
 }
 
+// AccountInfo returns the account information and space usage of the user
+func (c *Client) AccountInfo() (info Info, usage SpaceUsage, err error) {
+	if err = c.rpc("/users/get_current_account", nil, &info); err != nil {
+		return
+	}
+	err = c.rpc("/users/get_space_usage", nil, &usage)
+	return
+}
+
 //get user account information
 func (c *Client) Info(){
-	status, body := c.apiRequest("POST", "/users/get_current_account", nil, nil, false)
-	if status != "200 OK" {
-		fmt.Println("error: bad server status:", status)
-		fmt.Println(string(body))
-		os.Exit(1)
-	} 
-    info := Info{}
-	err := json.Unmarshal([]byte(body), &info)
+	info, usage, err := c.AccountInfo()
 	if err != nil {fmt.Println(err); os.Exit(1)}
-	
-	status, body = c.apiRequest("POST", "/users/get_space_usage", nil, nil, false)
-	if status != "200 OK" {
-		fmt.Println("error: bad server status:", status)
-		fmt.Println(string(body))
-		os.Exit(1)
+	if Structured() {
+		PrintUsage([]AccountUsage{accountUsage(c.User, info, usage)})
+		return
 	}
-	usage := SpaceUsage{}
-	err = json.Unmarshal([]byte(body), &usage)
-	if err != nil {fmt.Println(err); os.Exit(1)}
-	
 	left, _  := utils.NiceBytes(usage.Allocation.Allocated - usage.Used)
 	quota, _ := utils.NiceBytes(usage.Allocation.Allocated)
 	used, _  := utils.NiceBytes(usage.Used)
@@ -298,8 +298,7 @@ func (c *Client) apiRequest(method, endpoint string, params interface{}, data in
 	var req *http.Request
 	if data != nil {
 		if isJson {
-			form := data.(map[string]string)
-			form_js, _ := json.Marshal(form)
+			form_js, _ := json.Marshal(data)
 			req, err = http.NewRequest(method, uri.String(), strings.NewReader(string(form_js)))
 		} else {	
 			form := data.(url.Values)
@@ -344,6 +343,149 @@ func (c *Client) apiRequest(method, endpoint string, params interface{}, data in
 	return resp.Status, body
 }
 
+// APIError is a bad server status, with the error summary of the response
+// body, e.g. "path/not_found/.."
+type APIError struct {
+		StatusCode int
+		Status     string
+		Summary    string
+		Body       []byte
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("error: bad server status: %s\n%s", e.Status, string(e.Body))
+}
+
+func newAPIError(resp *http.Response, body []byte) *APIError {
+	var res struct {
+			ErrorSummary string `json:"error_summary"`
+	}
+	json.Unmarshal(body, &res)
+	return &APIError{resp.StatusCode, resp.Status, res.ErrorSummary, body}
+}
+
+// StatusCode returns the http status of err if it is an APIError, 0 otherwise
+func StatusCode(err error) int {
+	if e, ok := err.(*APIError); ok {
+		return e.StatusCode
+	}
+	return 0
+}
+
+// HasErrorTag reports whether err is an APIError whose summary has tag
+// among its parts, e.g. "conflict" for "to/conflict/file/.."
+func HasErrorTag(err error, tag string) bool {
+	e, ok := err.(*APIError)
+	if !ok {
+		return false
+	}
+	for _, t := range strings.Split(e.Summary, "/") {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// rpcRaw posts json params to an rpc endpoint and returns the raw response
+// body. Unlike apiRequest it neither prints nor exits: transport failures
+// and bad statuses are returned to the caller.
+func (c *Client) rpcRaw(endpoint string, params interface{}) ([]byte, error) {
+	var data io.Reader
+	if params != nil {
+		js, err := json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+		data = bytes.NewReader(js)
+	}
+	req, err := http.NewRequest("POST", c.BaseUrl + endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Auth.Token)
+	// endpoints without arguments take no body nor json content type
+	if params != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return body, newAPIError(resp, body)
+	}
+	return body, nil
+}
+
+// rpc posts json params to an rpc endpoint and decodes the response into res
+func (c *Client) rpc(endpoint string, params, res interface{}) error {
+	body, err := c.rpcRaw(endpoint, params)
+	if err != nil {
+		return err
+	}
+	if res == nil || len(body) == 0 || string(body) == "null" {
+		return nil
+	}
+	return json.Unmarshal(body, res)
+}
+
+// async job launch/check response envelope
+type asyncStatus struct {
+		Tag        string `json:".tag"`
+		AsyncJobId string `json:"async_job_id"`
+}
+
+// waitJob takes the response body of an async launch and, if the server
+// returned a job id, polls checkEp until the job is no longer in progress.
+// It returns the body of the final response.
+func (c *Client) waitJob(checkEp string, body []byte) ([]byte, error) {
+	return c.waitJobProgress(checkEp, body, nil)
+}
+
+// waitJobProgress is waitJob calling progress, if not nil, with the time
+// elapsed after each check of a job in progress
+func (c *Client) waitJobProgress(checkEp string, body []byte, progress func(time.Duration)) ([]byte, error) {
+	start := time.Now()
+	var st asyncStatus
+	if err := json.Unmarshal(body, &st); err != nil {
+		return nil, err
+	}
+	if st.Tag != "async_job_id" {
+		return jobResult(st.Tag, body)
+	}
+	id := st.AsyncJobId
+	for {
+		time.Sleep(JobPollInterval)
+		body, err := c.rpcRaw(checkEp, map[string]string{"async_job_id": id})
+		if err != nil {
+			return nil, err
+		}
+		st = asyncStatus{}
+		if err = json.Unmarshal(body, &st); err != nil {
+			return nil, err
+		}
+		if st.Tag != "in_progress" {
+			return jobResult(st.Tag, body)
+		}
+		if progress != nil {
+			progress(time.Since(start))
+		}
+	}
+}
+
+func jobResult(tag string, body []byte) ([]byte, error) {
+	if tag == "failed" {
+		return nil, fmt.Errorf("error: async job failed: %s", string(body))
+	}
+	return body, nil
+}
+
 func (c *Client) GetMetadata(path string) (meta Meta, err error){
 	ep := "/files/alpha/get_metadata"
 	params := map[string]string{"path":path} 
@@ -362,22 +504,15 @@ func (c *Client) GetMetadata(path string) (meta Meta, err error){
 	}
 }
 
-func fromJson(body []byte) (data DboxFolder) {
-	err := json.Unmarshal(body, &data)
-	if err != nil {
-		fmt.Println("error while decoding response body:", err)
-		fmt.Println("body", string(body))
-	}
-	return
-}
-
-func printRes(contents Entries) {
-	sort.Sort(ByName(contents))
+func printRes(contents Entries, opts ListOptions) {
+	SortEntries(contents, opts)
 	for _, v := range contents {
-		if v.Size == 0 {
+		if opts.Long {
+			printLong(v, opts)
+		} else if v.Tag != "file" {
 			fmt.Println(v.Name)
 		} else {
-			filesize, _ := utils.NiceBytes(v.Size)
+			filesize := listSize(v.Size, opts.Bytes)
 			fmt.Printf("%-68s %8s\n", v.Name, filesize)
 		}
 	}
@@ -394,10 +529,14 @@ func Legend(users map[string]string) string {
 }
 
 func printCompiled(contents []Entry) {
+	if Structured() {
+		Emit(entryRows(contents))
+		return
+	}
 	for _, v := range contents {
 		name := v.Name
 		userId := users[v.User]
-		if v.Size == 0 {
+		if v.Tag != "file" {
 			fmt.Printf("[%s] %s\n", userId, name)
 		} else {
 			size, _ := utils.NiceBytes(v.Size)
@@ -407,35 +546,91 @@ func printCompiled(contents []Entry) {
 	fmt.Printf("\n[%s]\n", Legend(users))
 }
 
-func (c *Client) getResource(path string) (data DboxFolder) {
-	ep := "/files/list_folder"
-	params := map[string]string{"path":path}
-	isJson := true
-	status, body := c.apiRequest("POST", ep, nil, params, isJson)
-	if status != "200 OK" {
-		fmt.Println("error: bad server status:", status+"\n"+string(body))
+type ListFolderArg struct {
+		Path             string `json:"path"`
+		Recursive        bool   `json:"recursive"`
+		IncludeDeleted   bool   `json:"include_deleted"`
+		IncludeMediaInfo bool   `json:"include_media_info,omitempty"`
+}
+
+// ListEntries returns all the entries matching arg, following the
+// list_folder cursor until the listing is complete. With a Cache, the
+// entries are read from it instead.
+func (c *Client) ListEntries(arg ListFolderArg) (entries Entries, err error) {
+	if c.Cache != nil && !arg.IncludeDeleted && !arg.IncludeMediaInfo {
+		return c.Cache.List(arg.Path, arg.Recursive), nil
+	}
+	_, err = c.listPages(arg, "", func(page Entries) {
+		entries = append(entries, page...)
+	})
+	return
+}
+
+// listPages calls fn with each page of the listing of arg, or of the
+// changes since cursor if not empty, and returns the latest cursor
+func (c *Client) listPages(arg ListFolderArg, cursor string, fn func(Entries)) (string, error) {
+	var res DboxFolder
+	var err error
+	if cursor == "" {
+		err = c.rpc("/files/list_folder", arg, &res)
 	} else {
-		//fmt.Println(string(body))
-		data = fromJson(body)	
-	}	
+		err = c.rpc("/files/list_folder/continue", map[string]string{"cursor": cursor}, &res)
+	}
+	for err == nil {
+		fn(res.Entries)
+		cursor = res.Cursor
+		if !res.HasMore {
+			break
+		}
+		res = DboxFolder{}
+		err = c.rpc("/files/list_folder/continue", map[string]string{"cursor": cursor}, &res)
+	}
+	return cursor, err
+}
+
+func (c *Client) getResource(path string) (data DboxFolder) {
+	entries, err := c.ListEntries(ListFolderArg{Path: path})
+	if err != nil {
+		fmt.Println(err)
+	}
+	data.Entries = entries
 	return
 }
 
-func (c *Client) ListFolder(path string){	
-	fmt.Printf("User: %s\n", c.User)
+func (c *Client) ListFolder(path string, opts ListOptions){	
 	res := c.getResource(path)
-	printRes(res.Entries)
+	var tags map[string]Tags
+	if opts.Tags {
+		tags = c.ReadAllTags(res.Entries)
+	}
+	if Structured() {
+		for k, _ := range res.Entries { res.Entries.SetUser(c.User, k) }
+		SortEntries(res.Entries, opts)
+		Emit(taggedRows(res.Entries, tags))
+		return
+	}
+	for k, e := range res.Entries {
+		if t, ok := tags[e.PathLower]; ok {
+			res.Entries[k].Name += "  [" + t.String() + "]"
+		}
+	}
+	fmt.Printf("User: %s\n", c.User)
+	printRes(res.Entries, opts)
 }
 
 
 func (c *Client) GetTree(path string, depth, d int) {
+	if Structured() {
+		c.emitTree(path, depth)
+		return
+	}
 	mx := 69
 	i := strings.Repeat("  ", d)
 	entries := c.getResource(path).Entries
 	sort.Sort(ByName(entries))
 	for _, e := range entries {
 		name := e.Name
-		if e.Size != 0 {
+		if e.Tag != "folder" {
 			if len(name) + len(i) > mx {
 				name = utils.Shorten(name, mx - len(i))
 			}
@@ -451,18 +646,40 @@ func (c *Client) GetTree(path string, depth, d int) {
 		}
 	}
 }
+
+// emitTree emits the records of the entries below path, down to depth levels (0 for all)
+func (c *Client) emitTree(path string, depth int) {
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: true})
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	base := len(splitPath(path))
+	var rows []Row
+	sort.Sort(ByPathLower(entries))
+	for _, e := range entries {
+		level := len(splitPath(e.PathLower)) - base
+		if level == 0 || (depth > 0 && level > depth) {
+			continue
+		}
+		e.User = c.User
+		rows = append(rows, EntryRecord(e))
+	}
+	Emit(rows)
+}
 	
 func (c *Client) ListAll(path string) {
 	var compiled []Entry
-	for user, _ := range users {  		
-		c.SetToken(user)
-		res := c.getResource(path)
-		var data Entries
-		data = res.Entries
-		for k, _ := range data { data.SetUser(user, k) }
-		compiled = append(data, compiled...) 		
+	results := ForEachAccount(Userlist(), Parallelism, func(d *Client) (interface{}, error) {
+		return d.ListEntries(ListFolderArg{Path: path})
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("error: listing failed for user %s: %s\n", r.User, r.Err)
+			continue
+		}
+		data := r.Value.(Entries)
+		for k, _ := range data { data.SetUser(r.User, k) }
+		compiled = append(compiled, data...) 		
 	}
-	sort.Sort(ByName(compiled))
+	sort.Stable(ByName(compiled))
 	printCompiled(compiled)
 }
 	
@@ -476,20 +693,17 @@ type Link struct {
     Link string `json:"link"`
 }
 	
-// get a streamable link to a file
+// get a streamable link to a file; failures are returned, never fatal, for
+// the long running stream server
 func (c *Client) getLink(path string) (link Link, err error) {
-    ep := "/files/get_temporary_link"
-	data := map[string]string{"path":path}
-	status, body := c.apiRequest("POST", ep, nil, data, true)
-    if status != "200 OK" {
-	   err = fmt.Errorf("error: bad server status: "+status+"\n"+string(body))
-	   return 
-	}
-    err = json.Unmarshal(body, &link)
-	return	 
+	err = c.rpc("/files/get_temporary_link", map[string]string{"path": path}, &link)
+	return
 }
 
 func (c *Client) GetLinks(path string, stream bool) [][]string {
+	if HasMeta(path) {
+		return c.globLinks(path, stream)
+	}
     links := [][]string{}
 	meta, err := c.GetMetadata(path)
 	if err != nil {fmt.Println(err); os.Exit(2)}	
@@ -500,7 +714,7 @@ func (c *Client) GetLinks(path string, stream bool) [][]string {
 		sort.Sort(ByName(items))
 		for _, i := range items{
 			if i.Tag != "folder" {
-				if stream && !isAudioExt(pth.Ext(i.Name)) {
+				if !c.Wanted(i, linkTypes(stream)...) {
 					continue 
 				}
 				filePath := pth.Join(folderName, i.Name)
@@ -523,42 +737,53 @@ func (c *Client) GetLinks(path string, stream bool) [][]string {
 	return links
 }
 
-func isAudioExt(p string) bool {
-	return utils.StringInSlice(pth.Ext(p), audioTypes)	
+// linkTypes returns the kinds of files to link: TypeFilter, or for streaming
+// the kinds a player handles
+func linkTypes(stream bool) []string {
+	if stream {
+		return playTypes()
+	}
+	return TypeFilter
 }
 
-func (c *Client) StreamLinks(path string) {
-    vlc := "C:/Program Files (x86)/VideoLAN/VLC/vlc.exe"
-	fb2k := "C:/Program Files (x86)/foobar2000/foobar2000.exe"
-	playerName := map[string]string{vlc: "VLC", fb2k: "foobar2000"}
-	
+// StreamLinks plays the media files under path in the preferred or first
+// suitable registered player, adding them to its playlist with enqueue
+func (c *Client) StreamLinks(path, preferred string, enqueue bool) {
 	links := c.GetLinks(path, true)
 
 	if len(links) == 0 {
-		fmt.Printf("didn't find any registered audio type file in %s", path)
+		fmt.Printf("didn't find any %s file in %s\n", strings.Join(playTypes(), " or "), path)
 		os.Exit(0)
 	}
-	
-    player := fb2k
-	for _, k := range links {
-		if utils.StringInSlice(pth.Ext(k[1]), unhandled) {
-			player = vlc 
-			break
-		}
+	players, configured, err := LoadPlayers()
+	if err != nil {
+		fmt.Println("warning: could not read players from config:", err)
+	}
+	if preferred == "" {
+		preferred = configured
+	}
+	var names []string
+	for _, f := range links {
+		names = append(names, f[0])
 	}
+	player, err := ChoosePlayer(players, preferred, names)
+	if err != nil {fmt.Println(err); os.Exit(1)}
 	fmt.Printf("got %d files\n", len(links))
-	var args []string	
-	if player == fb2k { args = append(args, "/add")}
+	// the files the player cannot play are left out
+	var urls []string
     for _, f := range links {
-		fmt.Println(f[0])
-		args = append(args, f[1])
+		if !player.Handles(f[0]) {
+			fmt.Println(f[0], "(unhandled)")
+		} else {
+			fmt.Println(f[0])
+			urls = append(urls, f[1])
+		}
 	}
-	if player == vlc { args = append(args, "--qt-start-minimized") }		
-	fmt.Println("\nlaunching", playerName[player])	
-    cmd := exec.Command(player, args...) 		 
+	fmt.Println("\nlaunching", player.Name)	
+    cmd := exec.Command(player.Command, player.Arguments(urls, enqueue)...) 		 
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr		 
-	err := cmd.Start()
+	err = cmd.Start()
 	if err != nil {log.Fatal(err)}  
 }
 
@@ -630,7 +855,7 @@ func (c *Client) downsync(path, folderpath string, aria, fast bool, depth, r, co
 	res := c.getResource(path)
 	items := res.Entries	
 	for _, e := range items {
-		if e.Tag != "folder" {
+		if e.Tag != "folder" && c.Wanted(e) {
 			filepath := folderpath + "/" + e.Name
 			fmt.Println("downloading", filepath)
 			var dlfast bool 
@@ -717,7 +942,7 @@ func (c *Client) parallelDownload(items []Entry, localFolder string, p int){
 	for k < len(items) {
 		var wg sync.WaitGroup
 		for d = 0; d < p; d += 1 {
-			if k+d < len(items) && items[k+d].Tag != "folder" {
+			if k+d < len(items) && items[k+d].Tag != "folder" && c.Wanted(items[k+d]) {
 				wg.Add(1)
 				f := items[k+d]
 				// anonymous func can be replaced by a named one 
@@ -832,8 +1057,17 @@ func (c *Client) pipedUpload(localPath, parent string) {
 	if err != nil {
 		fmt.Println(err, string(body))
 	} else {
-		fmt.Printf("%+v\n", meta) 
+		PrintUploaded(meta)
+	}
+}
+
+// PrintUploaded reports the metadata of an uploaded file
+func PrintUploaded(meta Meta) {
+	if Structured() {
+		PrintMeta(meta)
+		return
 	}
+	fmt.Printf("%+v\n", meta) 
 }
 
 func check(err error) {
@@ -1035,7 +1269,7 @@ func (c *Client) ChunkedUpload(localPath, parent string) {
             if ((filesize - position) <= Chunksize){
                 fmt.Println("Last chunk, finishing upload session...")
                 finish := c.uploadSessionFinish(fh, cursor, remotePath)
-                fmt.Printf("%+v\n", finish)
+                PrintUploaded(finish)
 				position += Chunksize
             } else {
                 fmt.Println("appending data; offset:", cursor.Offset)
@@ -1047,81 +1281,130 @@ func (c *Client) ChunkedUpload(localPath, parent string) {
 	}
 }
 
+type SearchOptions struct {
+		Path         string
+		MaxResults   int      // 0 for all the matches
+		FilenameOnly bool
+		Extensions   []string // e.g. "flac", "mp3"
+		Categories   []string // image, document, pdf, spreadsheet, presentation, audio, video, folder, paper, others
+}
+
+type searchOptions struct {
+		Path           string   `json:"path,omitempty"`
+		MaxResults     int      `json:"max_results,omitempty"`
+		FilenameOnly   bool     `json:"filename_only"`
+		FileExtensions []string `json:"file_extensions,omitempty"`
+		FileCategories []string `json:"file_categories,omitempty"`
+}
+
 type Search struct {
 		Matches []Match `json:"matches"`
-		More bool `json:"more"`
-		Start int `json:"start"`
+		HasMore bool    `json:"has_more"`
+		Cursor  string  `json:"cursor"`
 }
 
 type Match struct {
 		MatchType struct {
 			Tag string `json:".tag"`
 		} `json:"match_type"`
-		Metadata Meta `json:"metadata"`		
-}
-
-type Matchset []Match 
-func(m Matchset) SetUser(user string, i int) {
-	m[i].Metadata.User = user 
+		Metadata struct {
+				Tag      string `json:".tag"`
+				Metadata Meta   `json:"metadata"`
+		} `json:"metadata"`
 }
 
-func (c *Client) doSearch(path, query string) Search {
-	var result Search	
-	ep := "/files/search"
-	params := map[string]string{"path": path, "query":query}
-	status, body := c.apiRequest("POST", ep, nil, params, true)
-	if status == "200 OK" {
-		err := json.Unmarshal(body, &result)
-		if err != nil { fmt.Println(err);os.Exit(1) }		
-	}	
-	return result
+// Search queries search_v2 and follows the cursor until all the matches,
+// or opts.MaxResults of them, have been collected
+func (c *Client) Search(query string, opts SearchOptions) (Metaset, error) {
+	var res Metaset
+	o := searchOptions{Path: opts.Path, FilenameOnly: opts.FilenameOnly, FileCategories: opts.Categories}
+	if opts.MaxResults > 0 && opts.MaxResults < 1000 {
+		o.MaxResults = opts.MaxResults
+	} else {
+		o.MaxResults = 1000
+	}
+	for _, ext := range opts.Extensions {
+		o.FileExtensions = append(o.FileExtensions, strings.TrimPrefix(ext, "."))
+	}
+	var result Search
+	params := map[string]interface{}{"query": query, "options": o}
+	err := c.rpc("/files/search_v2", params, &result)
+	for err == nil {
+		for _, m := range result.Matches {
+			e := metaEntry(m.Metadata.Metadata)
+			if len(TypeFilter) > 0 && (e.Tag == "folder" || !c.Wanted(e)) {
+				continue
+			}
+			res = append(res, m.Metadata.Metadata)
+			if opts.MaxResults > 0 && len(res) == opts.MaxResults {
+				return res, nil
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+		cursor := result.Cursor
+		result = Search{}
+		err = c.rpc("/files/search/continue_v2", map[string]string{"cursor": cursor}, &result)
+	}
+	return res, err
 }
 
-
-func (c *Client) SearchUser(path, query string) {
-	result := c.doSearch(path, query)
-	matches := result.Matches
-	count := len(matches)
+func printMatches(matches Metaset, path string) {
+	if Structured() {
+		Emit(metaRows(matches))
+		return
+	}
 	if path == "" {
 		path = "/"
-	} else if path [:1] != "/" { path = "/"+path}
-	fmt.Printf("found %d item(s) in %s:\n\n", count, path)
-	for _,e := range matches {
-		if e.Metadata.Tag != "folder" {
-			size, _ := utils.NiceBytes(e.Metadata.Size)
-			fmt.Printf("%-70s %8s\n", e.Metadata.Name, size)
+	}
+	fmt.Printf("found %d item(s) in %s:\n\n", len(matches), path)
+	for _, m := range matches {
+		prefix := ""
+		if m.User != "" {
+			prefix = fmt.Sprintf("[%s] ", users[m.User])
+		}
+		if m.Tag != "folder" {
+			size, _ := utils.NiceBytes(m.Size)
+			fmt.Printf("%s%-70s %8s  %s\n", prefix, m.PathDisplay, size, m.ServerModified)
 		} else {
-			fmt.Println(e.Metadata.Name)
+			fmt.Printf("%s%s\n", prefix, m.PathDisplay)
 		}
 	}
 }
 
-func (c *Client) SearchAll(path, query string){
-	var res []Match	
-	for user, _ := range users {
-		var result Matchset
-		c.SetToken(user)
-		resp := c.doSearch(path, query)
-		result = resp.Matches
+func (c *Client) SearchUser(query string, opts SearchOptions) {
+	matches, err := c.Search(query, opts)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	for k, _ := range matches {
+		matches.SetUser(c.User, k)
+	}
+	printMatches(matches, opts.Path)
+}
+
+func (c *Client) SearchAll(query string, opts SearchOptions){
+	var res Metaset
+	results := ForEachAccount(Userlist(), Parallelism, func(d *Client) (interface{}, error) {
+		return d.Search(query, opts)
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			fmt.Printf("error: search failed for user %s: %s\n", r.User, r.Err)
+			continue
+		}
+		result := r.Value.(Metaset)
 		for k, _ := range result {
-			result.SetUser(user, k)
+			result.SetUser(r.User, k)
 		}
-		res = append(result, res...)
+		res = append(res, result...)
 	}	
-	//sort.Sort(ByName(res))
-	if path == "" {
-		path = "/"
-	} else if path [:1] != "/" { path = "/"+path}
-	fmt.Printf("found %d items in %v:\n\n", len(res), path)
-	for _, e := range res {
-		if e.Metadata.Tag != "folder" {
-			size, _ := utils.NiceBytes(e.Metadata.Size)
-			fmt.Printf("[%s] %-65s %8s\n", users[e.Metadata.User], e.Metadata.PathDisplay, size)
-		} else {
-			fmt.Printf("[%s] %s\n", users[e.Metadata.User], e.Metadata.PathDisplay)
-		}
+	printMatches(res, opts.Path)
+	if !Structured() {
+		fmt.Printf("\n[%s]\n", Legend(users))
 	}
-	fmt.Printf("\n[%s]\n", Legend(users))			       
 }
 
 func (c *Client) mkfolder(foldername, parent string) (p string) {
@@ -1155,23 +1438,16 @@ func (c *Client) CreateFolder(foldername, parent string) {
 }
 
 func (c *Client) Move(src, dest string) {  
-	type Resp struct {
-			Metadata Meta `json:"metadata"` 
-	}	
     if src[:1] != "/"{
 		src = "/" + src
 	}
-    form := map[string]string{"from_path": src, "to_path": dest}
-	status, body := c.apiRequest("POST", "/files/move_v2", nil, form, true)
-	if	status != "200 OK" { fmt.Println("bad status:", status); os.Exit(1)}
-	var res Resp
-    err := json.Unmarshal(body, &res)
-    if err != nil {fmt.Println(err); os.Exit(1)}
-	if res.Metadata.Tag != "folder" {
-		size, _ := utils.NiceBytes(res.Metadata.Size)
-		fmt.Printf("path:%s size:%s\n", res.Metadata.PathDisplay, size)
+	meta, err := c.MoveEntry(src, dest, RelocationOptions{})
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	if meta.Tag != "folder" {
+		size, _ := utils.NiceBytes(meta.Size)
+		fmt.Printf("path:%s size:%s\n", meta.PathDisplay, size)
 	} else {
-		fmt.Println("path:", res.Metadata.PathDisplay)
+		fmt.Println("path:", meta.PathDisplay)
 	}
 }
 
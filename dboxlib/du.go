@@ -0,0 +1,178 @@
+package dboxlib
+
+import(
+	"io"
+	"fmt"
+	"sort"
+	"bufio"
+	"strings"
+	"strconv"
+	pth "path"
+	"github.com/xiconet/utils"
+)
+
+type DuNode struct {
+		Path     string     `json:"path"`
+		Name     string     `json:"name"`
+		Size     int64      `json:"size"`  // cumulative size of the files below
+		Files    int        `json:"files"` // cumulative file count
+		Children []*DuNode  `json:"-"`
+		parent   *DuNode
+}
+
+type BySize []*DuNode
+
+func (a BySize) Len() int           { return len(a) }
+func (a BySize) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a BySize) Less(i, j int) bool {
+	if a[i].Size != a[j].Size {
+		return a[i].Size > a[j].Size
+	}
+	return strings.ToLower(a[i].Path) < strings.ToLower(a[j].Path)
+}
+
+// DiskUsage recursively lists path and returns the tree of its folders
+// with their cumulative size and file count, children sorted by size, then
+// by path
+func (c *Client) DiskUsage(path string) (*DuNode, error) {
+	path = cleanRoot(path)
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: true})
+	if err != nil {
+		return nil, err
+	}
+	return buildUsage(path, entries), nil
+}
+
+// cleanRoot cleans path, the root folder being ""
+func cleanRoot(path string) string {
+	if path = pth.Clean("/" + path); path == "/" {
+		return ""
+	}
+	return path
+}
+
+func buildUsage(path string, entries Entries) *DuNode {
+	path = cleanRoot(path)
+	root := &DuNode{Path: path, Name: pth.Base(path)}
+	if path == "" {
+		root.Path, root.Name = "/", "/"
+	}
+	rootKey := strings.ToLower(path)
+	nodes := map[string]*DuNode{rootKey: root}
+	parentKey := func(p string) string {
+		if k := pth.Dir(p); k != "/" {
+			return k
+		}
+		return ""
+	}
+	// the listing order does not guarantee parents come first
+	for _, e := range entries {
+		if e.Tag == "folder" && e.PathLower != rootKey {
+			nodes[e.PathLower] = &DuNode{Path: e.PathDisplay, Name: e.Name}
+		}
+	}
+	for k, n := range nodes {
+		if k == rootKey {
+			continue
+		}
+		if p, ok := nodes[parentKey(k)]; ok {
+			n.parent = p
+			p.Children = append(p.Children, n)
+		}
+	}
+	for _, e := range entries {
+		if e.Tag != "file" {
+			continue
+		}
+		for n := nodes[parentKey(e.PathLower)]; n != nil; n = n.parent {
+			n.Size += e.Size
+			n.Files += 1
+		}
+	}
+	for _, n := range nodes {
+		sort.Sort(BySize(n.Children))
+	}
+	return root
+}
+
+func duSize(size int64, human bool) string {
+	if !human {
+		return strconv.FormatInt(size, 10)
+	}
+	s, _ := utils.NiceBytes(size)
+	return s
+}
+
+func (n *DuNode) Fields() []string {
+	return []string{"path", "size", "files"}
+}
+
+func (n *DuNode) Values() []string {
+	return []string{n.Path, strconv.FormatInt(n.Size, 10), strconv.Itoa(n.Files)}
+}
+
+func usageRows(node *DuNode, depth, d int) []Row {
+	rows := []Row{&DuNode{Path: node.Path, Name: node.Name, Size: node.Size, Files: node.Files}}
+	if depth == 0 || d+1 < depth {
+		for _, ch := range node.Children {
+			rows = append(rows, usageRows(ch, depth, d+1)...)
+		}
+	}
+	return rows
+}
+
+// PrintUsageTree prints the size and file count of node and its sub-folders
+// down to depth levels (0 for all)
+func PrintUsageTree(node *DuNode, depth int, human bool) {
+	if Structured() {
+		Emit(usageRows(node, depth, 0))
+		return
+	}
+	printUsageNode(node, depth, 0, human)
+}
+
+func printUsageNode(node *DuNode, depth, d int, human bool) {
+	fmt.Printf("%12s %8d  %s%s\n", duSize(node.Size, human), node.Files, strings.Repeat("  ", d), node.Path)
+	if depth == 0 || d+1 < depth {
+		for _, ch := range node.Children {
+			printUsageNode(ch, depth, d+1, human)
+		}
+	}
+}
+
+// BrowseUsage is an ncdu-like browser reading commands from in: the
+// number of a folder to enter it, ".." to go up and "q" to quit
+func BrowseUsage(root *DuNode, in io.Reader, human bool) {
+	scanner := bufio.NewScanner(in)
+	node := root
+	for {
+		fmt.Printf("\n--- %s  %s in %d file(s)\n", node.Path, duSize(node.Size, human), node.Files)
+		for k, ch := range node.Children {
+			bar := 0
+			if node.Size > 0 {
+				bar = int(ch.Size * 20 / node.Size)
+			}
+			fmt.Printf("%4d %12s [%-20s] %s/\n", k+1, duSize(ch.Size, human), strings.Repeat("#", bar), ch.Name)
+		}
+		fmt.Print("number, .. or q> ")
+		if !scanner.Scan() {
+			return
+		}
+		cmd := strings.TrimSpace(scanner.Text())
+		switch cmd {
+		case "q":
+			return
+		case "..":
+			if node.parent != nil {
+				node = node.parent
+			}
+		default:
+			k, err := strconv.Atoi(cmd)
+			if err != nil || k < 1 || k > len(node.Children) {
+				fmt.Println("invalid choice:", cmd)
+				continue
+			}
+			node = node.Children[k-1]
+		}
+	}
+}
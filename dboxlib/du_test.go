@@ -0,0 +1,54 @@
+package dboxlib
+
+import(
+	"testing"
+)
+
+func TestBuildUsage(t *testing.T) {
+	entries := Entries{
+		{Tag: "file", PathLower: "/music/b/2.mp3", PathDisplay: "/Music/b/2.mp3", Size: 20},
+		{Tag: "folder", PathLower: "/music", PathDisplay: "/Music", Name: "Music"},
+		{Tag: "folder", PathLower: "/music/b", PathDisplay: "/Music/b", Name: "b"},
+		{Tag: "folder", PathLower: "/music/a", PathDisplay: "/Music/a", Name: "a"},
+		{Tag: "folder", PathLower: "/music/c", PathDisplay: "/Music/c", Name: "c"},
+		{Tag: "file", PathLower: "/music/a/1.mp3", PathDisplay: "/Music/a/1.mp3", Size: 20},
+		{Tag: "file", PathLower: "/music/c/3.mp3", PathDisplay: "/Music/c/3.mp3", Size: 50},
+		{Tag: "file", PathLower: "/music/x.txt", PathDisplay: "/Music/x.txt", Size: 5},
+		{Tag: "deleted", PathLower: "/music/gone.mp3", PathDisplay: "/Music/gone.mp3"},
+	}
+	for _, path := range []string{"/Music", "/music/", "/Music//", "Music"} {
+		root := buildUsage(path, entries)
+		if root.Size != 95 || root.Files != 4 {
+			t.Errorf("buildUsage(%q): size %d, %d files, want 95, 4", path, root.Size, root.Files)
+		}
+		var got []string
+		for _, ch := range root.Children {
+			got = append(got, ch.Path)
+		}
+		// equal sizes in path order
+		want := []string{"/Music/c", "/Music/a", "/Music/b"}
+		if len(got) != len(want) {
+			t.Fatalf("buildUsage(%q): children %v, want %v", path, got, want)
+		}
+		for k := range want {
+			if got[k] != want[k] {
+				t.Errorf("buildUsage(%q): children %v, want %v", path, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestBuildUsageRoot(t *testing.T) {
+	entries := Entries{
+		{Tag: "folder", PathLower: "/a", PathDisplay: "/a", Name: "a"},
+		{Tag: "file", PathLower: "/a/1", PathDisplay: "/a/1", Size: 3},
+		{Tag: "file", PathLower: "/2", PathDisplay: "/2", Size: 4},
+	}
+	for _, path := range []string{"", "/"} {
+		root := buildUsage(path, entries)
+		if root.Path != "/" || root.Size != 7 || root.Files != 2 || len(root.Children) != 1 || root.Children[0].Size != 3 {
+			t.Errorf("buildUsage(%q) = %+v", path, root)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package dboxlib
+
+import(
+	"fmt"
+	"sort"
+	"encoding/json"
+	"github.com/xiconet/utils"
+)
+
+type DupeGroup struct {
+		ContentHash string
+		Size        int64
+		Files       Entries // sorted by account and path, the first one is kept
+}
+
+// Wasted returns the bytes used by the extra copies
+func (g DupeGroup) Wasted() int64 {
+	return int64(len(g.Files)-1) * g.Size
+}
+
+type ByWasted []DupeGroup
+
+func (a ByWasted) Len() int           { return len(a) }
+func (a ByWasted) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByWasted) Less(i, j int) bool { return a[i].Wasted() > a[j].Wasted() }
+
+type byAccountPath []Entry
+
+func (a byAccountPath) Len() int      { return len(a) }
+func (a byAccountPath) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a byAccountPath) Less(i, j int) bool {
+	if a[i].User != a[j].User {
+		return a[i].User < a[j].User
+	}
+	return a[i].PathLower < a[j].PathLower
+}
+
+type dupeKey struct {
+		hash string
+		size int64
+}
+
+// FindDupes recursively lists path in every account and groups the
+// non-empty files having the same content_hash and size. Accounts that
+// could not be listed are returned with their error.
+func FindDupes(accounts []string, path string) ([]DupeGroup, []AccountResult) {
+	var failed []AccountResult
+	files := map[dupeKey]Entries{}
+	results := ForEachAccount(accounts, Parallelism, func(d *Client) (interface{}, error) {
+		return d.ListEntries(ListFolderArg{Path: path, Recursive: true})
+	})
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r)
+			continue
+		}
+		for _, e := range r.Value.(Entries) {
+			if e.Tag != "file" || e.Size == 0 || e.ContentHash == "" {
+				continue
+			}
+			e.User = r.User
+			k := dupeKey{e.ContentHash, e.Size}
+			files[k] = append(files[k], e)
+		}
+	}
+	var groups []DupeGroup
+	for k, f := range files {
+		if len(f) < 2 {
+			continue
+		}
+		sort.Sort(byAccountPath(f))
+		groups = append(groups, DupeGroup{k.hash, k.size, f})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Files[0].PathLower < groups[j].Files[0].PathLower })
+	sort.Stable(ByWasted(groups))
+	return groups, failed
+}
+
+// WastedBytes sums the bytes used by the extra copies in each account
+func WastedBytes(groups []DupeGroup) map[string]int64 {
+	wasted := map[string]int64{}
+	for _, g := range groups {
+		for _, f := range g.Files[1:] {
+			wasted[f.User] += g.Size
+		}
+	}
+	return wasted
+}
+
+type DeletePlan struct {
+		Keep   []PlanEntry `json:"keep"`
+		Delete []PlanEntry `json:"delete"`
+}
+
+type PlanEntry struct {
+		Account     string `json:"account"`
+		Path        string `json:"path"`
+		Size        int64  `json:"size"`
+		ContentHash string `json:"content_hash"`
+}
+
+// Plan returns which copy of each group to keep and which ones to delete
+func Plan(groups []DupeGroup) (plan DeletePlan) {
+	for _, g := range groups {
+		for k, f := range g.Files {
+			e := PlanEntry{f.User, f.PathDisplay, f.Size, f.ContentHash}
+			if k == 0 {
+				plan.Keep = append(plan.Keep, e)
+			} else {
+				plan.Delete = append(plan.Delete, e)
+			}
+		}
+	}
+	return
+}
+
+func PrintPlan(groups []DupeGroup) {
+	js, _ := json.MarshalIndent(Plan(groups), "", "  ")
+	fmt.Println(string(js))
+}
+
+func PrintDupes(groups []DupeGroup) {
+	if Structured() {
+		var rows []Row
+		for _, g := range groups {
+			rows = append(rows, entryRows(g.Files)...)
+		}
+		Emit(rows)
+		return
+	}
+	for _, g := range groups {
+		size, _ := utils.NiceBytes(g.Size)
+		fmt.Printf("%s %s x%d\n", g.ContentHash[:12], size, len(g.Files))
+		for _, f := range g.Files {
+			fmt.Printf("  [%s] %s\n", users[f.User], f.PathDisplay)
+		}
+	}
+	var total int64
+	wasted := WastedBytes(groups)
+	accounts := make([]string, 0, len(wasted))
+	for user, _ := range wasted {
+		accounts = append(accounts, user)
+	}
+	sort.Strings(accounts)
+	fmt.Printf("\n%d group(s) of duplicates\n", len(groups))
+	for _, user := range accounts {
+		size, _ := utils.NiceBytes(wasted[user])
+		fmt.Printf("[%s] wasted: %s\n", users[user], size)
+		total += wasted[user]
+	}
+	size, _ := utils.NiceBytes(total)
+	fmt.Printf("total wasted: %s\n", size)
+	fmt.Printf("\n[%s]\n", Legend(users))
+}
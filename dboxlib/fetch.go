@@ -0,0 +1,128 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"sync"
+	"time"
+	"bufio"
+	"strings"
+	"net/url"
+	"encoding/json"
+	pth "path"
+)
+
+// FetchURL has Dropbox download url into the file at path, without going
+// through this machine, and waits for the copy to complete. With progress,
+// the elapsed time is shown while waiting.
+func (c *Client) FetchURL(rawurl, path string, progress bool) (meta Meta, err error) {
+	body, err := c.rpcRaw("/files/save_url", map[string]string{"path": path, "url": rawurl})
+	if err != nil {
+		return
+	}
+	var show func(time.Duration)
+	if progress {
+		show = func(d time.Duration) {
+			fmt.Printf("\rfetching %s ... %s", rawurl, d.Round(time.Second))
+		}
+	}
+	body, err = c.waitJobProgress("/files/save_url/check_job_status", body, show)
+	if progress {
+		fmt.Println()
+	}
+	if err != nil {
+		return
+	}
+	// the complete status holds the metadata of the file
+	err = json.Unmarshal(body, &meta)
+	meta.Tag = "file"
+	return
+}
+
+// URLName returns the file name part of a url
+func URLName(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || pth.Base(u.Path) == "/" || pth.Base(u.Path) == "." {
+		return ""
+	}
+	return pth.Base(u.Path)
+}
+
+// FetchJob is a url to save at Path, with the metadata of the saved file
+// or the error once run
+type FetchJob struct {
+		URL  string
+		Path string
+		Meta Meta
+		Err  error
+}
+
+// ReadFetchList reads a list of urls, one per line optionally followed by
+// the name to save it as, and returns the jobs to save them in folder.
+// Blank lines and lines starting with # are skipped.
+func ReadFetchList(r io.Reader, folder string) ([]FetchJob, error) {
+	var jobs []FetchJob
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// the url and name may be separated by any blanks, tabs included
+		fields := strings.Fields(line)
+		name := URLName(fields[0])
+		if len(fields) > 1 {
+			name = strings.Join(fields[1:], " ")
+		}
+		if name == "" {
+			return jobs, fmt.Errorf("error: no file name in %s, give one after the url", fields[0])
+		}
+		jobs = append(jobs, FetchJob{URL: fields[0], Path: pth.Join(folder, name)})
+	}
+	return jobs, scanner.Err()
+}
+
+// FetchAll runs the jobs, Parallelism at a time, and returns them with
+// their result. Each one is reported as it completes, or when structured,
+// the saved files are emitted together once all are done and the errors
+// go to stderr.
+func (c *Client) FetchAll(jobs []FetchJob) []FetchJob {
+	limit := Parallelism
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan bool, limit)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for k, _ := range jobs {
+		wg.Add(1)
+		go func(j *FetchJob) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			j.Meta, j.Err = c.FetchURL(j.URL, j.Path, false)
+			mu.Lock()
+			defer mu.Unlock()
+			if j.Err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", j.URL, j.Err)
+				return
+			}
+			j.Meta.User = c.User
+			if !Structured() {
+				PrintUploaded(j.Meta)
+			}
+		}(&jobs[k])
+	}
+	wg.Wait()
+	if Structured() {
+		var metas []Meta
+		for _, j := range jobs {
+			if j.Err == nil {
+				metas = append(metas, j.Meta)
+			}
+		}
+		Emit(metaRows(metas))
+	}
+	return jobs
+}
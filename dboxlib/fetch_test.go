@@ -0,0 +1,53 @@
+package dboxlib
+
+import(
+	"strings"
+	"testing"
+)
+
+func TestURLName(t *testing.T) {
+	tests := []struct {
+			url  string
+			want string
+	}{
+		{"https://example.com/files/report.pdf", "report.pdf"},
+		{"https://example.com/files/report.pdf?dl=1#top", "report.pdf"},
+		{"https://example.com/a%20b.txt", "a b.txt"},
+		{"https://example.com/", ""},
+		{"https://example.com", ""},
+		{"://bad", ""},
+	}
+	for _, tt := range tests {
+		if got := URLName(tt.url); got != tt.want {
+			t.Errorf("URLName(%q) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestReadFetchList(t *testing.T) {
+	tests := []struct {
+			name string
+			list string
+			want []string // url > path
+			err  bool
+	}{
+		{"names", "https://x.org/a.txt\nhttps://x.org/b.txt b.bak\n", []string{"https://x.org/a.txt > /in/a.txt", "https://x.org/b.txt > /in/b.bak"}, false},
+		{"blanks", "\n  # comment\n\thttps://x.org/a.txt\t  my   file.txt  \r\n\n", []string{"https://x.org/a.txt > /in/my file.txt"}, false},
+		{"tab", "https://x.org/a.txt\tc.txt", []string{"https://x.org/a.txt > /in/c.txt"}, false},
+		{"no name", "https://x.org/a.txt\nhttps://x.org/\n", []string{"https://x.org/a.txt > /in/a.txt"}, true},
+		{"empty", "", nil, false},
+	}
+	for _, tt := range tests {
+		jobs, err := ReadFetchList(strings.NewReader(tt.list), "/in")
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want %v", tt.name, err, tt.err)
+		}
+		var got []string
+		for _, j := range jobs {
+			got = append(got, j.URL + " > " + j.Path)
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: jobs %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package dboxlib
+
+import(
+	"os"
+	"fmt"
+	"sort"
+	"strings"
+	pth "path"
+	ospath "path/filepath"
+)
+
+type ByPathLower []Entry
+
+func (a ByPathLower) Len() int           { return len(a) }
+func (a ByPathLower) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByPathLower) Less(i, j int) bool { return a[i].PathLower < a[j].PathLower }
+
+// HasMeta reports whether path contains any of the special characters
+// recognized by MatchPath
+func HasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// GlobBase returns the longest folder path of pattern free of wildcards
+func GlobBase(pattern string) string {
+	base := pth.Dir(pattern)
+	for HasMeta(base) {
+		base = pth.Dir(base)
+	}
+	if base == "/" || base == "." {
+		return ""
+	}
+	return base
+}
+
+// MatchPath reports whether path matches the pattern. Path elements are matched
+// as with path.Match (*, ?, [classes]), and a "**" element matches zero or
+// more folders. Like Dropbox paths, the match is case-insensitive.
+func MatchPath(pattern, path string) bool {
+	return matchSegs(splitPath(strings.ToLower(pattern)), splitPath(strings.ToLower(path)))
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
+
+func matchSegs(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegs(pat[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := pth.Match(pat[0], name[0]); !ok || err != nil {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// Glob returns the entries matching pattern, case-insensitively, sorted by
+// path. The folders are walked through paginated listings: one per folder
+// matched by a wildcard element, or a single recursive one from the first
+// "**".
+func (c *Client) Glob(pattern string) (Entries, error) {
+	entries, err := c.glob("", splitPath(strings.ToLower(pattern)))
+	sort.Sort(ByPathLower(entries))
+	return entries, err
+}
+
+func (c *Client) glob(base string, segs []string) (Entries, error) {
+	i := 0
+	for i < len(segs) && !HasMeta(segs[i]) {
+		base += "/" + segs[i]
+		i++
+	}
+	if i == len(segs) {
+		if base == "" {
+			return nil, nil
+		}
+		var meta Meta
+		err := c.rpc("/files/get_metadata", map[string]string{"path": base}, &meta)
+		if HasErrorTag(err, "not_found") {
+			// no such path, no match
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return Entries{metaEntry(meta)}, nil
+	}
+	var matches Entries
+	if segs[i] == "**" {
+		entries, err := c.ListEntries(ListFolderArg{Path: base, Recursive: true})
+		if err != nil {
+			return nil, err
+		}
+		depth := len(splitPath(base))
+		for _, e := range entries {
+			rel := splitPath(e.PathLower)
+			if len(rel) <= depth {
+				continue // the base folder itself
+			}
+			if matchSegs(segs[i:], rel[depth:]) {
+				matches = append(matches, e)
+			}
+		}
+		return matches, nil
+	}
+	entries, err := c.ListEntries(ListFolderArg{Path: base})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if ok, _ := pth.Match(segs[i], pth.Base(e.PathLower)); !ok {
+			continue
+		}
+		if i == len(segs)-1 {
+			matches = append(matches, e)
+		} else if e.Tag == "folder" {
+			sub, err := c.glob(e.PathDisplay, segs[i+1:])
+			if err != nil {
+				return matches, err
+			}
+			matches = append(matches, sub...)
+		}
+	}
+	return matches, nil
+}
+
+func metaEntry(m Meta) Entry {
+	tag := m.Tag
+	if tag == "" {
+		tag = "file"
+	}
+	return Entry{Tag: tag, Name: m.Name, PathLower: m.PathLower, PathDisplay: m.PathDisplay, Id: m.Id, Size: m.Size}
+}
+
+// DownloadGlob downloads the entries matching pattern, keeping their
+// folder structure relative to the wildcard-free part of the pattern
+func (c *Client) DownloadGlob(pattern string, aria, fast bool, depth, parallel, conns int) {
+	entries, err := c.Glob(pattern)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	if len(entries) == 0 {
+		fmt.Println("no match for", pattern)
+		return
+	}
+	base := len(splitPath(GlobBase(pattern)))
+	for _, e := range topLevel(entries) {
+		if !c.Wanted(e) {
+			continue
+		}
+		localPath := ospath.Join(splitPath(e.PathDisplay)[base:]...)
+		if err := os.MkdirAll(ospath.Dir(localPath), 0777); err != nil {
+			fmt.Println(err); os.Exit(1)
+		}
+		fmt.Println("downloading", localPath)
+		c.Download(e.PathDisplay, localPath, aria, fast, depth, parallel, conns)
+	}
+}
+
+// topLevel drops the entries below a folder among entries, which are
+// downloaded with it
+func topLevel(entries Entries) Entries {
+	folders := map[string]bool{}
+	for _, e := range entries {
+		if e.Tag == "folder" {
+			folders[e.PathLower] = true
+		}
+	}
+	var top Entries
+	for _, e := range entries {
+		nested := false
+		for dir := pth.Dir(e.PathLower); dir != "/" && dir != "."; dir = pth.Dir(dir) {
+			if folders[dir] {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			top = append(top, e)
+		}
+	}
+	return top
+}
+
+// globLinks returns the temporary links of the files matching pattern
+func (c *Client) globLinks(pattern string, stream bool) [][]string {
+	links := [][]string{}
+	entries, err := c.Glob(pattern)
+	if err != nil {fmt.Println(err); os.Exit(1)}
+	for _, e := range entries {
+		if e.Tag == "folder" || !c.Wanted(e, linkTypes(stream)...) {
+			continue
+		}
+		if link, err := c.getLink(e.PathDisplay); err == nil {
+			links = append(links, []string{e.PathDisplay, link.Link})
+		}
+	}
+	if !stream {
+		for _, k := range links {
+			fmt.Println(k)
+		}
+	}
+	return links
+}
@@ -0,0 +1,69 @@
+package dboxlib
+
+import(
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+			pattern, path string
+			want          bool
+	}{
+		{"/music/*.mp3", "/music/a.mp3", true},
+		{"/music/*.mp3", "/music/sub/a.mp3", false},
+		{"/music/*.MP3", "/Music/A.mp3", true},
+		{"/music/?.mp3", "/music/ab.mp3", false},
+		{"/music/[ab].mp3", "/music/B.mp3", true},
+		{"/music/**", "/music", true},
+		{"/music/**", "/music/a/b/c.flac", true},
+		{"/music/**/*.flac", "/music/c.flac", true},
+		{"/music/**/*.flac", "/music/a/b/c.flac", true},
+		{"/music/**/*.flac", "/music/a/b/c.mp3", false},
+		{"/**/live/*", "/music/2019/live/a.mp3", true},
+		{"/**/live/*", "/music/2019/studio/a.mp3", false},
+		{"/photos/*", "/photos", false},
+		{"/photos/[", "/photos/[", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+			pattern, want string
+	}{
+		{"/music/*.mp3", "/music"},
+		{"/music/**/live/*", "/music"},
+		{"/*/a", ""},
+		{"/a/b/c?", "/a/b"},
+	}
+	for _, tt := range tests {
+		if got := GlobBase(tt.pattern); got != tt.want {
+			t.Errorf("GlobBase(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestTopLevel(t *testing.T) {
+	entries := Entries{
+		{Tag: "folder", PathLower: "/a"},
+		{Tag: "file", PathLower: "/a b"},
+		{Tag: "file", PathLower: "/a/x.txt"},
+		{Tag: "folder", PathLower: "/a/sub"},
+		{Tag: "file", PathLower: "/a/sub/y.txt"},
+		{Tag: "file", PathLower: "/ab/z.txt"},
+	}
+	want := []string{"/a", "/a b", "/ab/z.txt"}
+	top := topLevel(entries)
+	if len(top) != len(want) {
+		t.Fatalf("topLevel returned %d entries, want %d: %v", len(top), len(want), top)
+	}
+	for k, e := range top {
+		if e.PathLower != want[k] {
+			t.Errorf("topLevel()[%d] = %q, want %q", k, e.PathLower, want[k])
+		}
+	}
+}
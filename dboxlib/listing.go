@@ -0,0 +1,91 @@
+package dboxlib
+
+import(
+	"fmt"
+	"sort"
+	"strconv"
+	"github.com/xiconet/utils"
+)
+
+var SortKeys = []string{"name", "size", "mtime"}
+
+type ListOptions struct {
+		Long         bool   // ls -l style, with type, mtime, rev and content_hash
+		SortBy       string // one of SortKeys, name by default
+		Reverse      bool
+		FoldersFirst bool
+		Bytes        bool   // sizes in bytes instead of human readable units
+		Tags         bool   // read the tags of the audio files
+}
+
+type entrySorter struct {
+		entries Entries
+		opts    ListOptions
+}
+
+func (s entrySorter) Len() int      { return len(s.entries) }
+func (s entrySorter) Swap(i, j int) { s.entries[i], s.entries[j] = s.entries[j], s.entries[i] }
+func (s entrySorter) Less(i, j int) bool {
+	a, b := s.entries[i], s.entries[j]
+	if s.opts.FoldersFirst && (a.Tag == "folder") != (b.Tag == "folder") {
+		return a.Tag == "folder"
+	}
+	var cmp int
+	switch s.opts.SortBy {
+	case "size":
+		cmp = compare(a.Size < b.Size, a.Size > b.Size)
+	case "mtime":
+		cmp = compare(a.ServerModified < b.ServerModified, a.ServerModified > b.ServerModified)
+	default:
+		cmp = compare(a.Name < b.Name, a.Name > b.Name)
+	}
+	if s.opts.Reverse {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+func compare(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+// SortEntries sorts entries in place according to opts
+func SortEntries(entries Entries, opts ListOptions) {
+	sort.Sort(ByName(entries))
+	sort.Stable(entrySorter{entries, opts})
+}
+
+// typeMarker returns the ls -l style type of an entry
+func typeMarker(e Entry) string {
+	switch e.Tag {
+	case "folder":
+		return "d"
+	case "deleted":
+		return "x"
+	}
+	return "-"
+}
+
+func listSize(size int64, bytes bool) string {
+	if bytes {
+		return strconv.FormatInt(size, 10)
+	}
+	s, _ := utils.NiceBytes(size)
+	return s
+}
+
+func printLong(e Entry, opts ListOptions) {
+	size, name := "", e.Name
+	if e.Tag == "file" {
+		size = listSize(e.Size, opts.Bytes)
+	} else if e.Tag == "folder" {
+		name += "/"
+	}
+	fmt.Printf("%s %10s %-20s %-15s %-64s %s\n", typeMarker(e), size, e.ServerModified, e.Rev, e.ContentHash, name)
+}
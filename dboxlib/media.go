@@ -0,0 +1,180 @@
+package dboxlib
+
+import(
+	"fmt"
+	"sync"
+	"strings"
+	"net/http"
+	"io/ioutil"
+	pth "path"
+	"menteslibres.net/gosexy/yaml"
+)
+
+// kinds of files, in the order they are tried by MediaType
+var MediaKinds = []string{"audio", "video", "image", "document"}
+
+// file extensions of each kind, updated from the "media_types" section of
+// the config file, which can also add new kinds:
+//
+//   media_types:
+//     audio: [".mp3", ".flac", ".dsf"]
+//     ebook: [".epub", ".mobi"]
+var MediaExtensions = map[string][]string{
+	"audio": {".mp3", ".flac", ".ogg", ".oga", ".opus", ".m4a", ".aac", ".mpc", ".wma", ".ape", ".wv", ".wav"},
+	"video": {".mkv", ".mp4", ".m4v", ".avi", ".webm", ".mov", ".wmv", ".mpg", ".mpeg", ".ts", ".flv"},
+	"image": {".jpg", ".jpeg", ".png", ".gif", ".bmp", ".tif", ".tiff", ".webp", ".heic", ".svg", ".raw", ".cr2", ".nef"},
+	"document": {".pdf", ".txt", ".md", ".doc", ".docx", ".odt", ".rtf", ".xls", ".xlsx", ".ods", ".ppt", ".pptx", ".odp", ".epub", ".csv"},
+}
+
+var(
+	TypeFilter []string // kinds kept by links, play, download and search, all if empty
+	Sniff      bool     // sniff the MIME type of files whose extension is unknown
+)
+
+var mediaOnce sync.Once
+
+func loadMediaTypes() {
+	config, err := yaml.Open(cfg_file)
+	if err != nil {
+		return
+	}
+	for kind, v := range toStringMap(config.Get("media_types")) {
+		var exts []string
+		for _, e := range toStrings(v) {
+			if !strings.HasPrefix(e, ".") {
+				e = "." + e
+			}
+			exts = append(exts, strings.ToLower(e))
+		}
+		if _, ok := MediaExtensions[kind]; !ok {
+			MediaKinds = append(MediaKinds, kind)
+		}
+		MediaExtensions[kind] = exts
+	}
+}
+
+// MediaType returns the kind of the file name from its extension, "" if unknown
+func MediaType(name string) string {
+	mediaOnce.Do(loadMediaTypes)
+	ext := strings.ToLower(pth.Ext(name))
+	if ext == "" {
+		return ""
+	}
+	for _, kind := range MediaKinds {
+		for _, e := range MediaExtensions[kind] {
+			if e == ext {
+				return kind
+			}
+		}
+	}
+	return ""
+}
+
+// mimeKind maps a MIME type to a kind, "" if none applies
+func mimeKind(mime string) string {
+	mime = strings.TrimSpace(strings.Split(mime, ";")[0])
+	switch {
+	case strings.HasPrefix(mime, "audio/"), mime == "application/ogg":
+		return "audio"
+	case strings.HasPrefix(mime, "video/"):
+		return "video"
+	case strings.HasPrefix(mime, "image/"):
+		return "image"
+	case strings.HasPrefix(mime, "text/"), mime == "application/pdf", mime == "application/postscript":
+		return "document"
+	}
+	return ""
+}
+
+// ReadRange returns the bytes start to end (inclusive) of the file at path,
+// or its last -start bytes if start is negative
+func (c *Client) ReadRange(path string, start, end int64) ([]byte, error) {
+	req, err := http.NewRequest("POST", content_url + "/files/download", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Auth.Token)
+	req.Header.Set("Dropbox-API-Arg", apiArg(map[string]string{"path": path}))
+	if start < 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d", start))
+	} else {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// empty file
+		return nil, nil
+	}
+	if resp.StatusCode != 200 && resp.StatusCode != 206 {
+		return nil, fmt.Errorf("error: bad server status: %s\n%s", resp.Status, string(body))
+	}
+	return body, err
+}
+
+// SniffType detects the MIME type of the file at path from its first bytes
+func (c *Client) SniffType(path string) (string, error) {
+	head, err := c.ReadRange(path, 0, 511)
+	if err != nil {
+		return "", err
+	}
+	return http.DetectContentType(head), nil
+}
+
+// Kind returns the kind of a file from its extension or, when that is
+// unknown and Sniff is set, from its content
+func (c *Client) Kind(e Entry) string {
+	kind := MediaType(e.Name)
+	if kind == "" && Sniff && e.Tag == "file" {
+		if mime, err := c.SniffType(e.PathDisplay); err == nil {
+			kind = mimeKind(mime)
+		}
+	}
+	return kind
+}
+
+// Wanted reports whether the file e is of one of the kinds in types, or
+// in TypeFilter if types is empty; folders are always wanted
+func (c *Client) Wanted(e Entry, types ...string) bool {
+	if len(types) == 0 {
+		types = TypeFilter
+	}
+	if len(types) == 0 || e.Tag == "folder" {
+		return true
+	}
+	kind := c.Kind(e)
+	for _, t := range types {
+		if t == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// playTypes returns the kinds a player should get: TypeFilter or else
+// audio and video
+func playTypes() []string {
+	if len(TypeFilter) > 0 {
+		return TypeFilter
+	}
+	return []string{"audio", "video"}
+}
+
+// CheckTypes returns an error if one of types is not a known kind
+func CheckTypes(types []string) error {
+	mediaOnce.Do(loadMediaTypes)
+	for _, t := range types {
+		known := false
+		for _, k := range MediaKinds {
+			known = known || k == t
+		}
+		if !known {
+			return fmt.Errorf("error: unknown file type %q, use one of %s", t, strings.Join(MediaKinds, ", "))
+		}
+	}
+	return nil
+}
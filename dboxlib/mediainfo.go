@@ -0,0 +1,249 @@
+package dboxlib
+
+import(
+	"os"
+	"fmt"
+	"sort"
+	"sync"
+	"strconv"
+)
+
+type Dimensions struct {
+		Width  int64 `json:"width"`
+		Height int64 `json:"height"`
+}
+
+type GpsCoordinates struct {
+		Latitude  float64 `json:"latitude"`
+		Longitude float64 `json:"longitude"`
+}
+
+type MediaMetadata struct {
+		Tag        string          `json:".tag"` // photo or video
+		Dimensions *Dimensions     `json:"dimensions,omitempty"`
+		Location   *GpsCoordinates `json:"location,omitempty"`
+		TimeTaken  string          `json:"time_taken,omitempty"`
+		Duration   int64           `json:"duration,omitempty"` // milliseconds, videos only
+}
+
+// MediaInfo is what Dropbox extracted from a photo or video; its tag is
+// "pending" until the extraction is done
+type MediaInfo struct {
+		Tag      string         `json:".tag"`
+		Metadata *MediaMetadata `json:"metadata,omitempty"`
+}
+
+var MediaSortKeys = []string{"taken", "name", "size", "resolution", "duration"}
+
+// GetMediaMetadata returns the metadata of the file at path with its media info
+func (c *Client) GetMediaMetadata(path string) (meta Meta, err error) {
+	err = c.rpc("/files/get_metadata", map[string]interface{}{"path": path, "include_media_info": true}, &meta)
+	return
+}
+
+// ListMedia returns the photos and videos in path, or below it if
+// recursive, with their media info. list_folder no longer returns media
+// info, so it is asked for with one get_metadata call per file,
+// Parallelism at a time: at most lookups files are looked up (no limit if
+// negative), the others are returned without info.
+func (c *Client) ListMedia(path string, recursive bool, lookups int) (Entries, error) {
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: recursive, IncludeMediaInfo: true})
+	if err != nil {
+		return nil, err
+	}
+	var media Entries
+	for _, e := range entries {
+		if e.Tag != "file" {
+			continue
+		}
+		if e.MediaInfo != nil || MediaType(e.Name) == "image" || MediaType(e.Name) == "video" {
+			media = append(media, e)
+		}
+	}
+	limit := Parallelism
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan bool, limit)
+	var wg sync.WaitGroup
+	skipped := 0
+	for k, e := range media {
+		if e.MediaInfo != nil {
+			continue
+		}
+		if lookups == 0 {
+			skipped += 1
+			continue
+		}
+		lookups -= 1
+		wg.Add(1)
+		go func(k int, path string) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			meta, err := c.GetMediaMetadata(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, path, err)
+				return
+			}
+			media[k].MediaInfo = meta.MediaInfo
+		}(k, e.PathDisplay)
+	}
+	wg.Wait()
+	if skipped > 0 {
+		fmt.Fprintf(os.Stderr, "media info not looked up for %d file(s)\n", skipped)
+	}
+	return media, nil
+}
+
+// mediaMetadata returns the extracted metadata of e, an empty one if none
+func mediaMetadata(e Entry) MediaMetadata {
+	if e.MediaInfo == nil || e.MediaInfo.Metadata == nil {
+		return MediaMetadata{}
+	}
+	return *e.MediaInfo.Metadata
+}
+
+func pixels(m MediaMetadata) int64 {
+	if m.Dimensions == nil {
+		return 0
+	}
+	return m.Dimensions.Width * m.Dimensions.Height
+}
+
+// SortMedia sorts entries by one of MediaSortKeys, files without the
+// information last whatever the order
+func SortMedia(entries Entries, key string, reverse bool) {
+	sort.Sort(ByPathLower(entries))
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := mediaMetadata(entries[i]), mediaMetadata(entries[j])
+		var cmp int
+		var hasA, hasB bool
+		switch key {
+		case "name":
+			hasA, hasB = true, true
+			cmp = compare(entries[i].Name < entries[j].Name, entries[i].Name > entries[j].Name)
+		case "size":
+			hasA, hasB = true, true
+			cmp = compare(entries[i].Size < entries[j].Size, entries[i].Size > entries[j].Size)
+		case "resolution":
+			hasA, hasB = pixels(a) > 0, pixels(b) > 0
+			cmp = compare(pixels(a) < pixels(b), pixels(a) > pixels(b))
+		case "duration":
+			hasA, hasB = a.Duration > 0, b.Duration > 0
+			cmp = compare(a.Duration < b.Duration, a.Duration > b.Duration)
+		default:
+			hasA, hasB = a.TimeTaken != "", b.TimeTaken != ""
+			cmp = compare(a.TimeTaken < b.TimeTaken, a.TimeTaken > b.TimeTaken)
+		}
+		if hasA != hasB {
+			return hasA
+		}
+		if reverse {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
+
+// MediaRecord is the structured output schema of the media report
+type MediaRecord struct {
+		Account   string   `json:"account"`
+		Path      string   `json:"path"`
+		Kind      string   `json:"kind"`
+		Width     int64    `json:"width"`
+		Height    int64    `json:"height"`
+		Duration  float64  `json:"duration"` // seconds
+		TimeTaken string   `json:"time_taken"`
+		Latitude  *float64 `json:"latitude,omitempty"`
+		Longitude *float64 `json:"longitude,omitempty"`
+		Pending   bool     `json:"pending"`
+}
+
+func NewMediaRecord(e Entry) MediaRecord {
+	m := mediaMetadata(e)
+	r := MediaRecord{Account: e.User, Path: e.PathDisplay, Kind: m.Tag, TimeTaken: m.TimeTaken}
+	r.Duration = float64(m.Duration) / 1000
+	if m.Dimensions != nil {
+		r.Width, r.Height = m.Dimensions.Width, m.Dimensions.Height
+	}
+	if m.Location != nil {
+		r.Latitude, r.Longitude = &m.Location.Latitude, &m.Location.Longitude
+	}
+	r.Pending = e.MediaInfo != nil && e.MediaInfo.Tag == "pending"
+	return r
+}
+
+func (r MediaRecord) Fields() []string {
+	return []string{"account", "path", "kind", "width", "height", "duration", "time_taken", "latitude", "longitude", "pending"}
+}
+
+func (r MediaRecord) Values() []string {
+	coord := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*f, 'f', 6, 64)
+	}
+	return []string{r.Account, r.Path, r.Kind, strconv.FormatInt(r.Width, 10), strconv.FormatInt(r.Height, 10),
+		strconv.FormatFloat(r.Duration, 'f', 3, 64), r.TimeTaken, coord(r.Latitude), coord(r.Longitude), strconv.FormatBool(r.Pending)}
+}
+
+// captureDate returns the day a photo or video was taken, or "unknown"
+func captureDate(e Entry) string {
+	if t := mediaMetadata(e).TimeTaken; len(t) >= 10 {
+		return t[:10]
+	}
+	return "unknown"
+}
+
+// PrintMedia prints the resolution, duration, time taken and location of
+// the entries, under a heading per capture date if byDate
+func PrintMedia(entries Entries, byDate bool) {
+	if byDate {
+		// the dates in order, files without one last
+		sort.SliceStable(entries, func(i, j int) bool {
+			a, b := captureDate(entries[i]), captureDate(entries[j])
+			if (a == "unknown") != (b == "unknown") {
+				return b == "unknown"
+			}
+			return a < b
+		})
+	}
+	if Structured() {
+		rows := make([]Row, len(entries))
+		for k, e := range entries {
+			rows[k] = NewMediaRecord(e)
+		}
+		Emit(rows)
+		return
+	}
+	date, pending := "", 0
+	for _, e := range entries {
+		if byDate && captureDate(e) != date {
+			date = captureDate(e)
+			fmt.Printf("\n%s\n", date)
+		}
+		r := NewMediaRecord(e)
+		if r.Pending {
+			pending += 1
+		}
+		resolution, duration, location := "", "", ""
+		if r.Width > 0 {
+			resolution = fmt.Sprintf("%dx%d", r.Width, r.Height)
+		}
+		if r.Duration > 0 {
+			d := int(r.Duration + 0.5)
+			duration = fmt.Sprintf("%d:%02d", d/60, d%60)
+		}
+		if r.Latitude != nil {
+			location = fmt.Sprintf("%.5f,%.5f", *r.Latitude, *r.Longitude)
+		}
+		fmt.Printf("%-11s %7s  %-20s  %-22s %s\n", resolution, duration, r.TimeTaken, location, e.PathDisplay)
+	}
+	fmt.Printf("\n%d file(s)", len(entries))
+	if pending > 0 {
+		fmt.Printf(", media info pending for %d", pending)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,72 @@
+package dboxlib
+
+import(
+	"strings"
+	"testing"
+)
+
+func mediaEntry(name string, size int64, m *MediaMetadata) Entry {
+	e := Entry{Tag: "file", Name: name, PathDisplay: "/" + name, PathLower: "/" + strings.ToLower(name), Size: size}
+	if m != nil {
+		e.MediaInfo = &MediaInfo{Tag: "metadata", Metadata: m}
+	}
+	return e
+}
+
+func TestSortMedia(t *testing.T) {
+	entries := Entries{
+		mediaEntry("a.jpg", 30, &MediaMetadata{Tag: "photo", TimeTaken: "2020-05-01T10:00:00Z", Dimensions: &Dimensions{800, 600}}),
+		mediaEntry("b.mp4", 10, &MediaMetadata{Tag: "video", TimeTaken: "2019-01-01T10:00:00Z", Duration: 5000, Dimensions: &Dimensions{1920, 1080}}),
+		mediaEntry("c.jpg", 20, nil),
+		mediaEntry("d.mp4", 40, &MediaMetadata{Tag: "video", Duration: 2000}),
+	}
+	tests := []struct {
+			key     string
+			reverse bool
+			want    string
+	}{
+		{"taken", false, "b.mp4 a.jpg c.jpg d.mp4"},
+		{"taken", true, "a.jpg b.mp4 c.jpg d.mp4"},
+		{"resolution", false, "a.jpg b.mp4 c.jpg d.mp4"},
+		{"resolution", true, "b.mp4 a.jpg c.jpg d.mp4"},
+		{"duration", false, "d.mp4 b.mp4 a.jpg c.jpg"},
+		{"duration", true, "b.mp4 d.mp4 a.jpg c.jpg"},
+		{"size", false, "b.mp4 c.jpg a.jpg d.mp4"},
+		{"name", true, "d.mp4 c.jpg b.mp4 a.jpg"},
+	}
+	for _, tt := range tests {
+		sorted := append(Entries{}, entries...)
+		SortMedia(sorted, tt.key, tt.reverse)
+		var names []string
+		for _, e := range sorted {
+			names = append(names, e.Name)
+		}
+		if got := strings.Join(names, " "); got != tt.want {
+			t.Errorf("SortMedia(%s, %v) = %s, want %s", tt.key, tt.reverse, got, tt.want)
+		}
+	}
+}
+
+func TestMediaRecord(t *testing.T) {
+	e := mediaEntry("b.mp4", 10, &MediaMetadata{Tag: "video", TimeTaken: "2019-01-01T10:00:00Z", Duration: 5250,
+		Dimensions: &Dimensions{1920, 1080}, Location: &GpsCoordinates{48.8566, 2.3522}})
+	e.User = "me"
+	want := []string{"me", "/b.mp4", "video", "1920", "1080", "5.250", "2019-01-01T10:00:00Z", "48.856600", "2.352200", "false"}
+	r := NewMediaRecord(e)
+	if got := r.Values(); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("Values() = %q, want %q", got, want)
+	}
+	if len(r.Fields()) != len(want) {
+		t.Errorf("%d fields for %d values", len(r.Fields()), len(want))
+	}
+	pending := Entry{PathDisplay: "/p.jpg", MediaInfo: &MediaInfo{Tag: "pending"}}
+	if r = NewMediaRecord(pending); !r.Pending || r.Width != 0 || r.Latitude != nil {
+		t.Errorf("pending record = %+v", r)
+	}
+	if d := captureDate(e); d != "2019-01-01" {
+		t.Errorf("captureDate = %q", d)
+	}
+	if d := captureDate(pending); d != "unknown" {
+		t.Errorf("captureDate of pending = %q", d)
+	}
+}
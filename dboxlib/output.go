@@ -0,0 +1,182 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"strconv"
+	"encoding/csv"
+	"encoding/json"
+	"text/template"
+)
+
+var(
+	// output format of the listings: text, json, jsonl, csv or tsv
+	Output = "text"
+	// text/template applied to each record instead of the output format, e.g. "{{.Path}} {{.Size}}"
+	Template = ""
+	Formats = []string{"text", "json", "jsonl", "csv", "tsv"}
+)
+
+// Row is an item of a structured output; its json encoding is used
+// for json/jsonl and templates, Fields and Values for csv/tsv
+type Row interface {
+	Fields() []string
+	Values() []string
+}
+
+// Record is the stable schema of files and folders in structured outputs
+type Record struct {
+		Account        string `json:"account"`
+		Path           string `json:"path"`
+		Name           string `json:"name"`
+		Type           string `json:"type"`
+		Size           int64  `json:"size"`
+		Rev            string `json:"rev"`
+		ContentHash    string `json:"content_hash"`
+		ServerModified string `json:"server_modified"`
+		Tags           *Tags  `json:"tags,omitempty"` // audio files, when read
+}
+
+func (r Record) Fields() []string {
+	return []string{"account", "path", "name", "type", "size", "rev", "content_hash", "server_modified"}
+}
+
+func (r Record) Values() []string {
+	return []string{r.Account, r.Path, r.Name, r.Type, strconv.FormatInt(r.Size, 10), r.Rev, r.ContentHash, r.ServerModified}
+}
+
+func (u AccountUsage) Fields() []string {
+	return []string{"account", "email", "used", "allocated", "free", "percent", "error"}
+}
+
+func (u AccountUsage) Values() []string {
+	return []string{u.Account, u.Email, strconv.FormatInt(u.Used, 10), strconv.FormatInt(u.Allocated, 10),
+		strconv.FormatInt(u.Free, 10), strconv.FormatFloat(u.Percent, 'f', 2, 64), u.Error}
+}
+
+func EntryRecord(e Entry) Record {
+	return Record{e.User, e.PathDisplay, e.Name, e.Tag, e.Size, e.Rev, e.ContentHash, e.ServerModified, nil}
+}
+
+func MetaRecord(m Meta) Record {
+	tag := m.Tag
+	if tag == "" {
+		tag = "file"
+	}
+	return Record{m.User, m.PathDisplay, m.Name, tag, m.Size, m.Rev, m.ContentHash, m.ServerModified, nil}
+}
+
+func entryRows(entries []Entry) []Row {
+	rows := make([]Row, len(entries))
+	for k, e := range entries {
+		rows[k] = EntryRecord(e)
+	}
+	return rows
+}
+
+// taggedRows is entryRows with the tags of the audio files found in tags
+func taggedRows(entries []Entry, tags map[string]Tags) []Row {
+	rows := make([]Row, len(entries))
+	for k, e := range entries {
+		r := EntryRecord(e)
+		if t, ok := tags[e.PathLower]; ok {
+			r.Tags = &t
+		}
+		rows[k] = r
+	}
+	return rows
+}
+
+func metaRows(metas []Meta) []Row {
+	rows := make([]Row, len(metas))
+	for k, m := range metas {
+		rows[k] = MetaRecord(m)
+	}
+	return rows
+}
+
+// Structured reports whether listings are printed in a structured format
+func Structured() bool {
+	return Output != "text" || Template != ""
+}
+
+// Emit prints rows to stdout in the selected structured format
+func Emit(rows []Row) {
+	if err := WriteRows(os.Stdout, Output, Template, rows); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+}
+
+// WriteRows writes rows to w in format, or with the template tmpl if not empty
+func WriteRows(w io.Writer, format, tmpl string, rows []Row) error {
+	if tmpl != "" {
+		t, err := template.New("row").Parse(tmpl + "\n")
+		if err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err = t.Execute(w, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	switch format {
+	case "json":
+		if rows == nil {
+			rows = []Row{}
+		}
+		js, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(js))
+		return err
+	case "jsonl":
+		enc := json.NewEncoder(w)
+		for _, r := range rows {
+			if err := enc.Encode(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if format == "tsv" {
+			cw.Comma = '\t'
+		}
+		for k, r := range rows {
+			if k == 0 {
+				cw.Write(r.Fields())
+			}
+			cw.Write(r.Values())
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// PrintMeta prints the metadata of a file or folder, one field per line
+func PrintMeta(meta Meta) {
+	r := MetaRecord(meta)
+	if Structured() {
+		Emit([]Row{r})
+		return
+	}
+	for k, f := range r.Fields() {
+		if v := r.Values()[k]; v != "" {
+			fmt.Printf("%-16s %s\n", f+":", v)
+		}
+	}
+	if meta.MediaInfo != nil {
+		m := NewMediaRecord(Entry{MediaInfo: meta.MediaInfo})
+		for k, f := range m.Fields()[2:] {
+			if v := m.Values()[k+2]; v != "" && v != "0" && v != "0.000" && v != "false" {
+				fmt.Printf("%-16s %s\n", f+":", v)
+			}
+		}
+	}
+}
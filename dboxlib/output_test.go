@@ -0,0 +1,60 @@
+package dboxlib
+
+import(
+	"bytes"
+	"testing"
+)
+
+func TestWriteRows(t *testing.T) {
+	rows := []Row{
+		Record{Account: "u1", Path: "/a.txt", Name: "a.txt", Type: "file", Size: 3, Rev: "r1"},
+		Record{Account: "u2", Path: "/b, c", Name: "b, c", Type: "folder"},
+	}
+	tests := []struct {
+			format, tmpl string
+			rows         []Row
+			want         string
+	}{
+		{"csv", "", rows, "account,path,name,type,size,rev,content_hash,server_modified\n" +
+			"u1,/a.txt,a.txt,file,3,r1,,\n" +
+			"u2,\"/b, c\",\"b, c\",folder,0,,,\n"},
+		{"tsv", "", rows[:1], "account\tpath\tname\ttype\tsize\trev\tcontent_hash\tserver_modified\n" +
+			"u1\t/a.txt\ta.txt\tfile\t3\tr1\t\t\n"},
+		{"jsonl", "", rows, `{"account":"u1","path":"/a.txt","name":"a.txt","type":"file","size":3,"rev":"r1","content_hash":"","server_modified":""}` + "\n" +
+			`{"account":"u2","path":"/b, c","name":"b, c","type":"folder","size":0,"rev":"","content_hash":"","server_modified":""}` + "\n"},
+		{"json", "", nil, "[]\n"},
+		{"csv", "", nil, ""},
+		{"json", "{{.Path}} {{.Size}}", rows, "/a.txt 3\n/b, c 0\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := WriteRows(&b, tt.format, tt.tmpl, tt.rows); err != nil {
+			t.Errorf("WriteRows(%s, %q): %v", tt.format, tt.tmpl, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("WriteRows(%s, %q) =\n%s\nwant\n%s", tt.format, tt.tmpl, b.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteRowsErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteRows(&b, "xml", "", nil); err == nil {
+		t.Error("WriteRows with an unknown format: no error")
+	}
+	if err := WriteRows(&b, "text", "{{.Path", nil); err == nil {
+		t.Error("WriteRows with a bad template: no error")
+	}
+}
+
+func TestRelocationRecord(t *testing.T) {
+	ok := NewRelocationRecord(RelocationResult{RelocationPath{"/a", "/b/a"}, Meta{PathDisplay: "/b/a", Size: 4}, nil})
+	if ok.Type != "file" || ok.Path != "/b/a" || ok.Size != 4 || ok.Error != "" {
+		t.Errorf("NewRelocationRecord(success) = %+v", ok)
+	}
+	failed := NewRelocationRecord(RelocationResult{RelocationPath{"/a", ""}, Meta{}, &APIError{Status: "409 Conflict", Body: []byte("x")}})
+	if failed.Type != "" || failed.Error == "" {
+		t.Errorf("NewRelocationRecord(failure) = %+v", failed)
+	}
+}
@@ -0,0 +1,205 @@
+package dboxlib
+
+import(
+	"fmt"
+	"strings"
+	"runtime"
+	"os/exec"
+	pth "path"
+	"menteslibres.net/gosexy/yaml"
+	"menteslibres.net/gosexy/to"
+)
+
+// Player describes how to launch a media player on a list of links.
+// In Args, the "{urls}" element is replaced by the links; Enqueue or
+// Replace are inserted before the links depending on the play mode.
+type Player struct {
+		Name       string
+		Command    string
+		Args       []string
+		Enqueue    []string
+		Replace    []string
+		Extensions []string // handled file types, all of them if empty
+}
+
+// builtinPlayers returns the default player profiles, most preferred first
+func builtinPlayers() []Player {
+	mediaOnce.Do(loadMediaTypes)
+	all := append(append([]string{}, MediaExtensions["audio"]...), MediaExtensions["video"]...)
+	mpv := Player{Name: "mpv", Command: "mpv", Args: []string{"--force-window=immediate", "{urls}"}, Extensions: all}
+	vlc := Player{
+		Name: "vlc",
+		Command: "vlc",
+		Args: []string{"--qt-start-minimized", "{urls}"},
+		Enqueue: []string{"--one-instance", "--playlist-enqueue"},
+		Replace: []string{"--one-instance"},
+		Extensions: all,
+	}
+	fb2k := Player{
+		Name: "foobar2000",
+		Command: "foobar2000",
+		Args: []string{"{urls}"},
+		Enqueue: []string{"/add"},
+		Extensions: []string{".mp3", ".flac", ".ogg", ".oga", ".opus", ".m4a", ".aac", ".mpc", ".wma"},
+	}
+	mplayer := Player{Name: "mplayer", Command: "mplayer", Args: []string{"{urls}"}, Extensions: all}
+	if runtime.GOOS == "windows" {
+		vlc.Command = "C:/Program Files (x86)/VideoLAN/VLC/vlc.exe"
+		fb2k.Command = "C:/Program Files (x86)/foobar2000/foobar2000.exe"
+		return []Player{fb2k, vlc, mpv, mplayer}
+	}
+	return []Player{mpv, vlc, mplayer, fb2k}
+}
+
+// Arguments returns the command line arguments to play urls
+func (p Player) Arguments(urls []string, enqueue bool) []string {
+	mode := p.Replace
+	if enqueue {
+		mode = p.Enqueue
+	}
+	var args []string
+	for _, a := range p.Args {
+		if a == "{urls}" {
+			args = append(args, mode...)
+			args = append(args, urls...)
+		} else {
+			args = append(args, a)
+		}
+	}
+	return args
+}
+
+// Handles reports whether the player can play the file name
+func (p Player) Handles(name string) bool {
+	if len(p.Extensions) == 0 {
+		return true
+	}
+	ext := strings.ToLower(pth.Ext(name))
+	for _, e := range p.Extensions {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// Installed reports whether the player command can be found
+func (p Player) Installed() bool {
+	_, err := exec.LookPath(p.Command)
+	return err == nil
+}
+
+// LoadPlayers returns the built-in players updated and extended with the
+// "players" section of the config file, and the preferred "player" if set:
+//
+//   player: vlc
+//   players:
+//     vlc:
+//       command: /usr/bin/vlc
+//       args: ["--no-video", "{urls}"]
+//       enqueue: ["--one-instance", "--playlist-enqueue"]
+//       replace: ["--one-instance"]
+//       extensions: [".mp3", ".flac"]
+func LoadPlayers() (players []Player, preferred string, err error) {
+	players = builtinPlayers()
+	config, err := yaml.Open(cfg_file)
+	if err != nil {
+		return
+	}
+	preferred = to.String(config.Get("player"))
+	for name, v := range toStringMap(config.Get("players")) {
+		conf := toStringMap(v)
+		k := len(players)
+		for i, p := range players {
+			if p.Name == name {
+				k = i
+			}
+		}
+		if k == len(players) {
+			players = append(players, Player{Name: name, Command: name, Args: []string{"{urls}"}})
+		}
+		p := &players[k]
+		if v, ok := conf["command"]; ok {
+			p.Command = to.String(v)
+		}
+		if v, ok := conf["args"]; ok {
+			p.Args = toStrings(v)
+		}
+		if v, ok := conf["enqueue"]; ok {
+			p.Enqueue = toStrings(v)
+		}
+		if v, ok := conf["replace"]; ok {
+			p.Replace = toStrings(v)
+		}
+		if v, ok := conf["extensions"]; ok {
+			p.Extensions = toStrings(v)
+		}
+	}
+	return
+}
+
+// ChoosePlayer returns the first installed player, starting with the
+// preferred one, handling all the files, or else the one handling most
+func ChoosePlayer(players []Player, preferred string, names []string) (Player, error) {
+	var ordered []Player
+	for _, p := range players {
+		if p.Name == preferred {
+			ordered = append(ordered, p)
+		}
+	}
+	for _, p := range players {
+		if p.Name != preferred {
+			ordered = append(ordered, p)
+		}
+	}
+	best, most := Player{}, 0
+	for _, p := range ordered {
+		if !p.Installed() {
+			continue
+		}
+		n := 0
+		for _, name := range names {
+			if p.Handles(name) {
+				n += 1
+			}
+		}
+		if n == len(names) {
+			return p, nil
+		}
+		if n > most {
+			best, most = p, n
+		}
+	}
+	if most == 0 {
+		return best, fmt.Errorf("error: no installed player for these files")
+	}
+	return best, nil
+}
+
+func toStringMap(v interface{}) map[string]interface{} {
+	m := map[string]interface{}{}
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return t
+	case map[interface{}]interface{}:
+		for k, v := range t {
+			m[to.String(k)] = v
+		}
+	}
+	return m
+}
+
+func toStrings(v interface{}) []string {
+	var l []string
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			l = append(l, to.String(e))
+		}
+	case []string:
+		return t
+	case string:
+		return strings.Fields(t)
+	}
+	return l
+}
@@ -0,0 +1,71 @@
+package dboxlib
+
+import(
+	"strings"
+	"testing"
+)
+
+func TestPlayerArguments(t *testing.T) {
+	vlc := Player{
+		Name: "vlc",
+		Args: []string{"--qt-start-minimized", "{urls}", "--extra"},
+		Enqueue: []string{"--one-instance", "--playlist-enqueue"},
+		Replace: []string{"--one-instance"},
+	}
+	fb2k := Player{Name: "foobar2000", Args: []string{"{urls}"}, Enqueue: []string{"/add"}}
+	urls := []string{"http://a", "http://b"}
+	tests := []struct {
+			p       Player
+			enqueue bool
+			want    string
+	}{
+		{vlc, false, "--qt-start-minimized --one-instance http://a http://b --extra"},
+		{vlc, true, "--qt-start-minimized --one-instance --playlist-enqueue http://a http://b --extra"},
+		{fb2k, false, "http://a http://b"},
+		{fb2k, true, "/add http://a http://b"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.p.Arguments(urls, tt.enqueue), " "); got != tt.want {
+			t.Errorf("%s.Arguments(enqueue: %v) = %q, want %q", tt.p.Name, tt.enqueue, got, tt.want)
+		}
+	}
+}
+
+func TestPlayerHandles(t *testing.T) {
+	p := Player{Extensions: []string{".mp3", ".FLAC"}}
+	for name, want := range map[string]bool{"a.mp3": true, "B.MP3": true, "c.flac": true, "d.mkv": false, "noext": false} {
+		if got := p.Handles(name); got != want {
+			t.Errorf("Handles(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !(Player{}).Handles("any.xyz") {
+		t.Error("a player without extensions should handle any file")
+	}
+}
+
+func TestChoosePlayer(t *testing.T) {
+	// "sh" is installed wherever the tests run, the other command is not
+	audio := Player{Name: "audio", Command: "sh", Extensions: []string{".mp3", ".flac"}}
+	video := Player{Name: "video", Command: "sh", Extensions: []string{".mkv"}}
+	missing := Player{Name: "missing", Command: "no-such-player-command"}
+	players := []Player{missing, audio, video}
+	tests := []struct {
+			preferred string
+			names     []string
+			want      string
+			fails     bool
+	}{
+		{"", []string{"a.mp3", "b.flac"}, "audio", false},
+		{"", []string{"a.mkv"}, "video", false},
+		{"video", []string{"a.mp3"}, "audio", false},
+		{"missing", []string{"a.mp3"}, "audio", false},
+		{"", []string{"a.mkv", "b.mkv", "c.mp3"}, "video", false},
+		{"", []string{"a.txt"}, "", true},
+	}
+	for _, tt := range tests {
+		p, err := ChoosePlayer(players, tt.preferred, tt.names)
+		if (err != nil) != tt.fails || p.Name != tt.want {
+			t.Errorf("ChoosePlayer(%q, %v) = %q, %v, want %q", tt.preferred, tt.names, p.Name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"sort"
+	"time"
+	"strings"
+	"math/rand"
+	"encoding/xml"
+	pth "path"
+)
+
+// temporary links are valid for four hours
+const LinkLifetime = 4 * time.Hour
+
+var PlaylistFormats = []string{"m3u8", "m3u", "xspf", "pls"}
+
+type PlaylistOptions struct {
+		Recursive bool
+		SortBy    string // "path" (default) or one of SortKeys
+		Reverse   bool
+		Shuffle   bool
+		Server    string // base URL of a stream server, for links that do not expire
+		Tags      bool   // read the titles and durations from the audio tags
+}
+
+type Track struct {
+		Title    string
+		Path     string
+		URL      string
+		Duration int // seconds, -1 if unknown
+}
+
+type Playlist struct {
+		Title   string
+		Tracks  []Track
+		Expires time.Time // when the first of the links expires, zero if they don't
+}
+
+// Playlist returns the playable files under path (or matching the pattern)
+// with a temporary link for each
+func (c *Client) Playlist(path string, opts PlaylistOptions) (pl Playlist, err error) {
+	var entries Entries
+	if HasMeta(path) {
+		entries, err = c.Glob(path)
+	} else {
+		var meta Meta
+		meta, err = c.GetMetadata(path)
+		if err != nil {
+			return
+		}
+		if meta.Tag == "folder" {
+			entries, err = c.ListEntries(ListFolderArg{Path: path, Recursive: opts.Recursive})
+		} else {
+			entries = Entries{{Tag: meta.Tag, Name: meta.Name, PathLower: meta.PathLower, PathDisplay: meta.PathDisplay, Size: meta.Size}}
+		}
+	}
+	if err != nil {
+		return
+	}
+	var files Entries
+	for _, e := range entries {
+		if e.Tag == "file" && c.Wanted(e, playTypes()...) {
+			files = append(files, e)
+		}
+	}
+	switch {
+	case opts.Shuffle:
+		rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+		rng.Shuffle(len(files), func(i, j int) { files[i], files[j] = files[j], files[i] })
+	case opts.SortBy == "" || opts.SortBy == "path":
+		sort.Sort(ByPathLower(files))
+		if opts.Reverse {
+			for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+				files[i], files[j] = files[j], files[i]
+			}
+		}
+	default:
+		SortEntries(files, ListOptions{SortBy: opts.SortBy, Reverse: opts.Reverse})
+	}
+	pl.Title = pth.Base(path)
+	var tags map[string]Tags
+	if opts.Tags {
+		tags = c.ReadAllTags(files)
+	}
+	if opts.Server == "" {
+		pl.Expires = time.Now().Add(LinkLifetime).UTC()
+	}
+	for _, e := range files {
+		track := newTrack(e, tags)
+		if opts.Server != "" {
+			track.URL = StreamURL(opts.Server, c.User, e.PathDisplay)
+		} else {
+			link, err := c.getLink(e.PathDisplay)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, e.PathDisplay, err)
+				continue
+			}
+			track.URL = link.Link
+		}
+		pl.Tracks = append(pl.Tracks, track)
+	}
+	return
+}
+
+// newTrack titles e from its tags if any, from its name otherwise
+func newTrack(e Entry, tags map[string]Tags) Track {
+	track := Track{Title: strings.TrimSuffix(e.Name, pth.Ext(e.Name)), Path: e.PathDisplay, Duration: -1}
+	if t, ok := tags[e.PathLower]; ok {
+		if t.Title != "" {
+			track.Title = t.Title
+			if t.Artist != "" {
+				track.Title = t.Artist + " - " + t.Title
+			}
+		}
+		if t.Duration > 0 {
+			track.Duration = int(t.Duration + 0.5)
+		}
+	}
+	return track
+}
+
+func expires(pl Playlist) string {
+	if pl.Expires.IsZero() {
+		return "never"
+	}
+	return pl.Expires.Format(time.RFC3339)
+}
+
+// WritePlaylist writes pl to w in one of PlaylistFormats
+func WritePlaylist(w io.Writer, pl Playlist, format string) error {
+	switch format {
+	case "m3u8", "m3u":
+		fmt.Fprintln(w, "#EXTM3U")
+		fmt.Fprintf(w, "#PLAYLIST:%s\n", pl.Title)
+		fmt.Fprintf(w, "# links expire at %s\n", expires(pl))
+		for _, t := range pl.Tracks {
+			fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", t.Duration, t.Title, t.URL)
+		}
+	case "pls":
+		fmt.Fprintln(w, "[playlist]")
+		fmt.Fprintf(w, "; links expire at %s\n", expires(pl))
+		for k, t := range pl.Tracks {
+			fmt.Fprintf(w, "File%d=%s\nTitle%d=%s\nLength%d=%d\n", k+1, t.URL, k+1, t.Title, k+1, t.Duration)
+		}
+		fmt.Fprintf(w, "NumberOfEntries=%d\nVersion=2\n", len(pl.Tracks))
+	case "xspf":
+		return writeXspf(w, pl)
+	default:
+		return fmt.Errorf("error: unknown playlist format %q, use one of %s", format, strings.Join(PlaylistFormats, ", "))
+	}
+	return nil
+}
+
+type xspfTrack struct {
+		Location string `xml:"location"`
+		Title    string `xml:"title"`
+		Duration int64  `xml:"duration,omitempty"` // milliseconds
+}
+
+type xspfPlaylist struct {
+		XMLName    xml.Name    `xml:"playlist"`
+		Version    string      `xml:"version,attr"`
+		Xmlns      string      `xml:"xmlns,attr"`
+		Title      string      `xml:"title"`
+		Annotation string      `xml:"annotation"`
+		Date       string      `xml:"date"`
+		Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+func writeXspf(w io.Writer, pl Playlist) error {
+	x := xspfPlaylist{
+		Version: "1",
+		Xmlns: "http://xspf.org/ns/0/",
+		Title: pl.Title,
+		Annotation: "links expire at " + expires(pl),
+		Date: time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, t := range pl.Tracks {
+		var ms int64
+		if t.Duration > 0 {
+			ms = int64(t.Duration) * 1000
+		}
+		x.Tracks = append(x.Tracks, xspfTrack{t.URL, t.Title, ms})
+	}
+	io.WriteString(w, xml.Header)
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(x); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,92 @@
+package dboxlib
+
+import(
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPlaylist() Playlist {
+	return Playlist{
+		Title: "Live & Loud",
+		Tracks: []Track{
+			{Title: "Artist - One", URL: "https://dl.example.com/1?a=1&b=2", Duration: 61},
+			{Title: "two", URL: "https://dl.example.com/2", Duration: -1},
+		},
+		Expires: time.Date(2018, 3, 1, 14, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestWritePlaylist(t *testing.T) {
+	tests := []struct {
+			format string
+			want   string
+	}{
+		{"m3u8", "#EXTM3U\n#PLAYLIST:Live & Loud\n# links expire at 2018-03-01T14:00:00Z\n" +
+			"#EXTINF:61,Artist - One\nhttps://dl.example.com/1?a=1&b=2\n#EXTINF:-1,two\nhttps://dl.example.com/2\n"},
+		{"pls", "[playlist]\n; links expire at 2018-03-01T14:00:00Z\n" +
+			"File1=https://dl.example.com/1?a=1&b=2\nTitle1=Artist - One\nLength1=61\n" +
+			"File2=https://dl.example.com/2\nTitle2=two\nLength2=-1\nNumberOfEntries=2\nVersion=2\n"},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		if err := WritePlaylist(&b, testPlaylist(), tt.format); err != nil {
+			t.Errorf("WritePlaylist(%s): %v", tt.format, err)
+			continue
+		}
+		if b.String() != tt.want {
+			t.Errorf("WritePlaylist(%s) =\n%s\nwant\n%s", tt.format, b.String(), tt.want)
+		}
+	}
+}
+
+func TestWritePlaylistXspf(t *testing.T) {
+	var b bytes.Buffer
+	pl := testPlaylist()
+	pl.Expires = time.Time{}
+	if err := WritePlaylist(&b, pl, "xspf"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`<playlist version="1" xmlns="http://xspf.org/ns/0/">`,
+		"<title>Live &amp; Loud</title>",
+		"<annotation>links expire at never</annotation>",
+		"<location>https://dl.example.com/1?a=1&amp;b=2</location>",
+		"<duration>61000</duration>",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("xspf playlist has no %s:\n%s", want, b.String())
+		}
+	}
+	if strings.Count(b.String(), "<duration>") != 1 {
+		t.Errorf("unknown durations should be left out:\n%s", b.String())
+	}
+}
+
+func TestWritePlaylistUnknownFormat(t *testing.T) {
+	var b bytes.Buffer
+	if err := WritePlaylist(&b, testPlaylist(), "wpl"); err == nil || b.Len() != 0 {
+		t.Errorf("WritePlaylist(wpl): %v, %q", err, b.String())
+	}
+}
+
+func TestNewTrack(t *testing.T) {
+	e := Entry{Name: "01 intro.mp3", PathLower: "/m/01 intro.mp3", PathDisplay: "/M/01 intro.mp3"}
+	tests := []struct {
+			tags  map[string]Tags
+			title string
+			dur   int
+	}{
+		{nil, "01 intro", -1},
+		{map[string]Tags{"/m/01 intro.mp3": {Title: "Intro", Duration: 59.6}}, "Intro", 60},
+		{map[string]Tags{"/m/01 intro.mp3": {Artist: "X", Title: "Intro"}}, "X - Intro", -1},
+		{map[string]Tags{"/m/01 intro.mp3": {Artist: "X"}}, "01 intro", -1},
+	}
+	for _, tt := range tests {
+		track := newTrack(e, tt.tags)
+		if track.Title != tt.title || track.Duration != tt.dur {
+			t.Errorf("newTrack(%v) = %q, %d, want %q, %d", tt.tags, track.Title, track.Duration, tt.title, tt.dur)
+		}
+	}
+}
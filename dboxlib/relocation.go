@@ -0,0 +1,327 @@
+package dboxlib
+
+import(
+	"fmt"
+	"strings"
+	"strconv"
+	"encoding/json"
+	pth "path"
+	"github.com/xiconet/utils"
+)
+
+// maximum number of entries per batch request
+const batchLimit = 1000
+
+type RelocationPath struct {
+		FromPath string `json:"from_path"`
+		ToPath   string `json:"to_path"`
+}
+
+type RelocationOptions struct {
+		Autorename             bool
+		AllowOwnershipTransfer bool
+}
+
+type RelocationResult struct {
+		RelocationPath
+		Metadata Meta
+		Err      error
+}
+
+type batchEntry struct {
+		Tag      string          `json:".tag"`
+		Success  Meta            `json:"success"`
+		Metadata Meta            `json:"metadata"` // delete_batch entries
+		Failure  json.RawMessage `json:"failure"`
+}
+
+type batchResult struct {
+		Entries []batchEntry `json:"entries"`
+}
+
+// Copy copies a file or folder from src to dst on the server
+func (c *Client) Copy(src, dst string, opts RelocationOptions) (meta Meta, err error) {
+	params := map[string]interface{}{
+		"from_path": src,
+		"to_path": dst,
+		"autorename": opts.Autorename,
+		"allow_ownership_transfer": opts.AllowOwnershipTransfer,
+	}
+	var res struct {
+			Metadata Meta `json:"metadata"`
+	}
+	err = c.rpc("/files/copy_v2", params, &res)
+	return res.Metadata, err
+}
+
+// CopyBatch copies many entries at once, polling the async job until completion
+func (c *Client) CopyBatch(entries []RelocationPath, opts RelocationOptions) ([]RelocationResult, error) {
+	params := map[string]interface{}{"autorename": opts.Autorename}
+	return c.relocateBatch("/files/copy_batch_v2", "/files/copy_batch/check_v2", entries, params)
+}
+
+// MoveEntry moves a file or folder from src to dst on the server
+func (c *Client) MoveEntry(src, dst string, opts RelocationOptions) (meta Meta, err error) {
+	params := map[string]interface{}{
+		"from_path": src,
+		"to_path": dst,
+		"autorename": opts.Autorename,
+		"allow_ownership_transfer": opts.AllowOwnershipTransfer,
+	}
+	var res struct {
+			Metadata Meta `json:"metadata"`
+	}
+	err = c.rpc("/files/move_v2", params, &res)
+	return res.Metadata, err
+}
+
+// MoveBatch moves many entries at once, polling the async job until completion
+func (c *Client) MoveBatch(entries []RelocationPath, opts RelocationOptions) ([]RelocationResult, error) {
+	params := map[string]interface{}{
+		"autorename": opts.Autorename,
+		"allow_ownership_transfer": opts.AllowOwnershipTransfer,
+	}
+	return c.relocateBatch("/files/move_batch_v2", "/files/move_batch/check_v2", entries, params)
+}
+
+// DeleteBatch deletes many paths at once, polling the async job until
+// completion. The results only have their FromPath set.
+func (c *Client) DeleteBatch(paths []string) ([]RelocationResult, error) {
+	var results []RelocationResult
+	for i := 0; i < len(paths); i += batchLimit {
+		batch := paths[i:]
+		if len(batch) > batchLimit {
+			batch = batch[:batchLimit]
+		}
+		var entries []map[string]string
+		for _, p := range batch {
+			entries = append(entries, map[string]string{"path": p})
+		}
+		body, err := c.rpcRaw("/files/delete_batch", map[string]interface{}{"entries": entries})
+		if err != nil {
+			return results, err
+		}
+		body, err = c.waitJob("/files/delete_batch/check", body)
+		if err != nil {
+			return results, err
+		}
+		var res batchResult
+		if err = json.Unmarshal(body, &res); err != nil {
+			return results, err
+		}
+		for k, e := range res.Entries {
+			r := RelocationResult{RelocationPath: RelocationPath{FromPath: batch[k]}}
+			if e.Tag == "success" {
+				r.Metadata = e.Metadata
+			} else {
+				r.Err = fmt.Errorf("%s", string(e.Failure))
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+func (c *Client) relocateBatch(ep, checkEp string, entries []RelocationPath, params map[string]interface{}) ([]RelocationResult, error) {
+	var results []RelocationResult
+	for i := 0; i < len(entries); i += batchLimit {
+		batch := entries[i:]
+		if len(batch) > batchLimit {
+			batch = batch[:batchLimit]
+		}
+		params["entries"] = batch
+		body, err := c.rpcRaw(ep, params)
+		if err != nil {
+			return results, err
+		}
+		body, err = c.waitJob(checkEp, body)
+		if err != nil {
+			return results, err
+		}
+		var res batchResult
+		if err = json.Unmarshal(body, &res); err != nil {
+			return results, err
+		}
+		for k, e := range res.Entries {
+			r := RelocationResult{RelocationPath: batch[k]}
+			if e.Tag == "success" {
+				r.Metadata = e.Success
+			} else {
+				r.Err = fmt.Errorf("%s", string(e.Failure))
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// expand returns the paths of the entries matching pattern, but not those
+// below a matching folder, or pattern itself if it has no wildcard
+func (c *Client) expand(pattern string) ([]string, error) {
+	if !HasMeta(pattern) {
+		return []string{pattern}, nil
+	}
+	// the paths to move or remove are never taken from a stale cache
+	live := *c
+	live.Cache = nil
+	entries, err := live.Glob(pattern)
+	var paths []string
+	for _, e := range topLevel(entries) {
+		paths = append(paths, e.PathDisplay)
+	}
+	return paths, err
+}
+
+// expandAll expands each pattern, failing if nothing matches at all. A
+// path given or matched more than once is only kept the first time.
+func (c *Client) expandAll(patterns []string) ([]string, error) {
+	var paths []string
+	seen := map[string]bool{}
+	for _, p := range patterns {
+		matches, err := c.expand(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			key := strings.ToLower(pth.Clean(m))
+			if !seen[key] {
+				seen[key] = true
+				paths = append(paths, m)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("error: no match for %v", patterns)
+	}
+	return paths, nil
+}
+
+// relocationPaths maps the expanded sources into the folder dst
+func (c *Client) relocationPaths(sources []string, dst string) ([]RelocationPath, error) {
+	paths, err := c.expandAll(sources)
+	if err != nil {
+		return nil, err
+	}
+	var entries []RelocationPath
+	for _, p := range paths {
+		entries = append(entries, RelocationPath{p, pth.Join(dst, pth.Base(p))})
+	}
+	return entries, nil
+}
+
+// CopyPaths copies the sources, which may contain wildcards, into the
+// folder dst. A single literal source is copied to dst itself.
+func (c *Client) CopyPaths(sources []string, dst string, opts RelocationOptions) ([]RelocationResult, error) {
+	if len(sources) == 1 && !HasMeta(sources[0]) {
+		meta, err := c.Copy(sources[0], dst, opts)
+		r := RelocationResult{RelocationPath{sources[0], dst}, meta, err}
+		return []RelocationResult{r}, nil
+	}
+	entries, err := c.relocationPaths(sources, dst)
+	if err != nil {
+		return nil, err
+	}
+	return c.CopyBatch(entries, opts)
+}
+
+// MovePaths moves the sources, which may contain wildcards, into the
+// folder dst. A single literal source is moved to dst itself.
+func (c *Client) MovePaths(sources []string, dst string, opts RelocationOptions) ([]RelocationResult, error) {
+	if len(sources) == 1 && !HasMeta(sources[0]) {
+		meta, err := c.MoveEntry(sources[0], dst, opts)
+		r := RelocationResult{RelocationPath{sources[0], dst}, meta, err}
+		return []RelocationResult{r}, nil
+	}
+	entries, err := c.relocationPaths(sources, dst)
+	if err != nil {
+		return nil, err
+	}
+	return c.MoveBatch(entries, opts)
+}
+
+// RemovalPaths returns the paths that removing patterns, which may contain
+// wildcards, would delete
+func (c *Client) RemovalPaths(patterns []string) ([]string, error) {
+	paths, err := c.expandAll(patterns)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range paths {
+		if p == "/" || p == "" {
+			return nil, fmt.Errorf("error: cannot remove root folder")
+		}
+	}
+	return paths, nil
+}
+
+// RemovePaths deletes the paths, which may contain wildcards
+func (c *Client) RemovePaths(patterns []string) ([]RelocationResult, error) {
+	paths, err := c.RemovalPaths(patterns)
+	if err != nil {
+		return nil, err
+	}
+	return c.DeleteBatch(paths)
+}
+
+// RelocationRecord is the structured output schema of copy, move and
+// remove results
+type RelocationRecord struct {
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Path  string `json:"path"`
+		Type  string `json:"type"`
+		Size  int64  `json:"size"`
+		Error string `json:"error"`
+}
+
+func NewRelocationRecord(r RelocationResult) RelocationRecord {
+	rec := RelocationRecord{From: r.FromPath, To: r.ToPath, Path: r.Metadata.PathDisplay, Size: r.Metadata.Size}
+	if r.Err != nil {
+		rec.Error = r.Err.Error()
+	} else if rec.Type = r.Metadata.Tag; rec.Type == "" {
+		rec.Type = "file"
+	}
+	return rec
+}
+
+func (r RelocationRecord) Fields() []string {
+	return []string{"from", "to", "path", "type", "size", "error"}
+}
+
+func (r RelocationRecord) Values() []string {
+	return []string{r.From, r.To, r.Path, r.Type, strconv.FormatInt(r.Size, 10), r.Error}
+}
+
+// PrintRelocations prints one line per entry and returns the number of failures
+func PrintRelocations(results []RelocationResult) (failed int) {
+	if Structured() {
+		rows := make([]Row, len(results))
+		for k, r := range results {
+			rows[k] = NewRelocationRecord(r)
+			if r.Err != nil {
+				failed += 1
+			}
+		}
+		Emit(rows)
+		return
+	}
+	for _, r := range results {
+		if r.Err != nil {
+			failed += 1
+			if r.ToPath == "" {
+				fmt.Printf("failed: %s: %s\n", r.FromPath, r.Err)
+			} else {
+				fmt.Printf("failed: %s -> %s: %s\n", r.FromPath, r.ToPath, r.Err)
+			}
+			continue
+		}
+		if r.Metadata.Tag != "folder" {
+			size, _ := utils.NiceBytes(r.Metadata.Size)
+			fmt.Printf("path:%s size:%s\n", r.Metadata.PathDisplay, size)
+		} else {
+			fmt.Println("path:", r.Metadata.PathDisplay)
+		}
+	}
+	fmt.Printf("%d succeeded, %d failed\n", len(results) - failed, failed)
+	return
+}
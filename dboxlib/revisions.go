@@ -0,0 +1,64 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"github.com/xiconet/utils"
+)
+
+type Revisions struct {
+		IsDeleted     bool    `json:"is_deleted"`
+		ServerDeleted string  `json:"server_deleted,omitempty"`
+		Entries       Metaset `json:"entries"`
+}
+
+// ListRevisions returns up to limit past revisions of the file at path, newest first
+func (c *Client) ListRevisions(path string, limit int) (revs Revisions, err error) {
+	params := map[string]interface{}{"path": path, "mode": "path", "limit": limit}
+	err = c.rpc("/files/list_revisions", params, &revs)
+	return
+}
+
+// Restore restores the file at path to the given revision
+func (c *Client) Restore(path, rev string) (meta Meta, err error) {
+	err = c.rpc("/files/restore", map[string]string{"path": path, "rev": rev}, &meta)
+	return
+}
+
+// DownloadRevision downloads a specific revision of a file to localPath
+// and returns its size
+func (c *Client) DownloadRevision(rev, localPath string) (int64, error) {
+	body, err := c.openDownload("rev:" + rev)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	out, err := os.Create(localPath)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, body)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(localPath)
+	}
+	return n, err
+}
+
+func PrintRevisions(revs Revisions) {
+	if Structured() {
+		Emit(metaRows(revs.Entries))
+		return
+	}
+	if revs.IsDeleted {
+		fmt.Println("deleted on", revs.ServerDeleted)
+	}
+	fmt.Printf("%-20s %10s  %-20s  %s\n", "rev", "size", "server_modified", "content_hash")
+	for _, m := range revs.Entries {
+		size, _ := utils.NiceBytes(m.Size)
+		fmt.Printf("%-20s %10s  %-20s  %s\n", m.Rev, size, m.ServerModified, m.ContentHash)
+	}
+}
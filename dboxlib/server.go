@@ -0,0 +1,169 @@
+package dboxlib
+
+import(
+	"io"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+	"strings"
+	"net/url"
+	"net/http"
+	"crypto/subtle"
+)
+
+// links are renewed this long before they expire
+const linkMargin = 10 * time.Minute
+
+type cachedLink struct {
+		url     string
+		expires time.Time
+}
+
+// StreamServer serves the files of all the accounts at stable URLs,
+// /<user>/<path>, proxying the requests to temporary links that are
+// resolved on demand and renewed when they expire. With a token, the
+// requests must give it as the token query parameter.
+type StreamServer struct {
+		Token   string
+		mu      sync.Mutex
+		clients map[string]*Client
+		links   map[string]cachedLink // by user + path_lower
+}
+
+func NewStreamServer(token string) *StreamServer {
+	return &StreamServer{Token: token, clients: map[string]*Client{}, links: map[string]cachedLink{}}
+}
+
+// StreamURL returns the stable URL of path in the account user on the
+// server at base, e.g. http://127.0.0.1:8080 or, for a server with a
+// token, http://192.168.1.2:8080/?token=secret
+func StreamURL(base, user, path string) string {
+	u := url.URL{Path: "/" + user + path}
+	b, err := url.Parse(base)
+	if err != nil {
+		return strings.TrimSuffix(base, "/") + u.EscapedPath()
+	}
+	query := ""
+	if b.RawQuery != "" {
+		query = "?" + b.RawQuery
+	}
+	b.RawQuery = ""
+	return strings.TrimSuffix(b.String(), "/") + u.EscapedPath() + query
+}
+
+func (s *StreamServer) client(user string) *Client {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.clients[user]
+	if !ok {
+		c = AccountClient(user)
+		s.clients[user] = c
+	}
+	return c
+}
+
+// link returns a temporary link to path, from the cache unless it is
+// about to expire or renew is set
+func (s *StreamServer) link(user, path string, renew bool) (string, error) {
+	key := user + ":" + strings.ToLower(path)
+	s.mu.Lock()
+	l, ok := s.links[key]
+	s.mu.Unlock()
+	if ok && !renew && time.Now().Add(linkMargin).Before(l.expires) {
+		return l.url, nil
+	}
+	expires := time.Now().Add(LinkLifetime)
+	link, err := s.client(user).getLink(path)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.links[key] = cachedLink{link.Link, expires}
+	s.mu.Unlock()
+	return link.Link, nil
+}
+
+var proxiedHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Etag", "Last-Modified"}
+
+func (s *StreamServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.Token != "" && subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(s.Token)) != 1 {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if _, ok := users[parts[0]]; !ok || len(parts) < 2 || parts[1] == "" {
+		http.NotFound(w, r)
+		return
+	}
+	user, path := parts[0], "/" + parts[1]
+	var resp *http.Response
+	for renew := false; ; renew = true {
+		link, err := s.link(user, path, renew)
+		if err != nil {
+			log.Println(user, path, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		req, _ := http.NewRequest(r.Method, link, nil)
+		for _, h := range []string{"Range", "If-Range"} {
+			if v := r.Header.Get(h); v != "" {
+				req.Header.Set(h, v)
+			}
+		}
+		resp, err = http.DefaultClient.Do(req)
+		if err != nil {
+			log.Println(user, path, err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		// an expired or revoked link: resolve it again, once
+		if renew || (resp.StatusCode != http.StatusGone && resp.StatusCode != http.StatusNotFound && resp.StatusCode != http.StatusForbidden) {
+			break
+		}
+		resp.Body.Close()
+	}
+	defer resp.Body.Close()
+	for _, h := range proxiedHeaders {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	log.Printf("%s /%s%s %s %d\n", r.Method, user, path, r.Header.Get("Range"), resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// Loopback reports whether addr, e.g. 127.0.0.1:8080, only listens on the
+// loopback interface
+func Loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve runs the stream server on addr, e.g. 127.0.0.1:8080. As it gives
+// access to all the accounts, it refuses to listen on other interfaces
+// than the loopback one without a token.
+func (s *StreamServer) Serve(addr string) error {
+	if !Loopback(addr) && s.Token == "" {
+		return fmt.Errorf("error: refusing to serve all the accounts on %s without a token", addr)
+	}
+	query := ""
+	if s.Token != "" {
+		query = "?token=<token>"
+	}
+	fmt.Printf("serving at http://%s/<user>/<path>%s\n", addr, query)
+	return http.ListenAndServe(addr, s)
+}
@@ -0,0 +1,65 @@
+package dboxlib
+
+import(
+	"testing"
+	"net/http"
+	"net/http/httptest"
+)
+
+func TestStreamURL(t *testing.T) {
+	tests := []struct {
+			base, user, path, want string
+	}{
+		{"http://127.0.0.1:8080", "bob", "/Music/a b.mp3", "http://127.0.0.1:8080/bob/Music/a%20b.mp3"},
+		{"http://127.0.0.1:8080/", "bob", "/x#1?.flac", "http://127.0.0.1:8080/bob/x%231%3F.flac"},
+		{"http://192.168.1.2:8080/?token=s3cret", "bob", "/a.mp3", "http://192.168.1.2:8080/bob/a.mp3?token=s3cret"},
+		{"http://host/proxy", "bob", "/a.mp3", "http://host/proxy/bob/a.mp3"},
+	}
+	for _, tt := range tests {
+		if got := StreamURL(tt.base, tt.user, tt.path); got != tt.want {
+			t.Errorf("StreamURL(%q, %q, %q) = %q, want %q", tt.base, tt.user, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoopback(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8080": true,
+		"localhost:8080": true,
+		"[::1]:8080": true,
+		":8080": false,
+		"0.0.0.0:8080": false,
+		"192.168.1.2:8080": false,
+		"8080": false,
+	} {
+		if got := Loopback(addr); got != want {
+			t.Errorf("Loopback(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestServeRefusesRemoteWithoutToken(t *testing.T) {
+	if err := NewStreamServer("").Serve("0.0.0.0:0"); err == nil {
+		t.Error("Serve on all interfaces without a token: no error")
+	}
+}
+
+func TestStreamServerAccess(t *testing.T) {
+	s := NewStreamServer("s3cret")
+	tests := []struct {
+			method, target string
+			want           int
+	}{
+		{"POST", "/u/a.mp3?token=s3cret", http.StatusMethodNotAllowed},
+		{"GET", "/u/a.mp3", http.StatusForbidden},
+		{"GET", "/u/a.mp3?token=wrong", http.StatusForbidden},
+		{"GET", "/no-such-user/a.mp3?token=s3cret", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest(tt.method, tt.target, nil))
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status %d, want %d", tt.method, tt.target, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package dboxlib
+
+import(
+	"fmt"
+	"strings"
+	"strconv"
+	"encoding/json"
+)
+
+// access levels accepted by the sharing endpoints
+var AccessLevels = []string{"owner", "editor", "viewer", "viewer_no_comment"}
+
+type Tagged struct {
+		Tag string `json:".tag"`
+}
+
+type SharedFolder struct {
+		AccessType          Tagged `json:"access_type"`
+		IsInsideTeamFolder  bool   `json:"is_inside_team_folder"`
+		IsTeamFolder        bool   `json:"is_team_folder"`
+		Name                string `json:"name"`
+		PathLower           string `json:"path_lower,omitempty"`
+		PreviewUrl          string `json:"preview_url"`
+		SharedFolderId      string `json:"shared_folder_id"`
+		TimeInvited         string `json:"time_invited"`
+		ParentSharedFolderId string `json:"parent_shared_folder_id,omitempty"`
+}
+
+// Mounted reports whether the shared folder is mounted in the user's Dropbox
+func (f SharedFolder) Mounted() bool {
+	return f.PathLower != ""
+}
+
+type sharedFolders struct {
+		Entries []SharedFolder `json:"entries"`
+		Cursor  string         `json:"cursor"`
+}
+
+type Member struct {
+		AccessType Tagged `json:"access_type"`
+		User struct {
+				AccountId   string `json:"account_id"`
+				Email       string `json:"email"`
+				DisplayName string `json:"display_name"`
+		} `json:"user"`
+		Invitee struct {
+				Tag   string `json:".tag"`
+				Email string `json:"email"`
+		} `json:"invitee"`
+		IsInherited bool `json:"is_inherited"`
+}
+
+// Email returns the address of a member or of a pending invitee
+func (m Member) Email() string {
+	if m.User.Email != "" {
+		return m.User.Email
+	}
+	return m.Invitee.Email
+}
+
+type folderMembers struct {
+		Users    []Member `json:"users"`
+		Invitees []Member `json:"invitees"`
+		Cursor   string   `json:"cursor"`
+}
+
+type memberSelector struct {
+		Tag   string `json:".tag"`
+		Email string `json:"email"`
+}
+
+type addMember struct {
+		Member      memberSelector `json:"member"`
+		AccessLevel Tagged         `json:"access_level"`
+}
+
+func isAccessLevel(level string) bool {
+	for _, l := range AccessLevels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// ShareFolder shares the folder at path, waiting for the job to complete
+func (c *Client) ShareFolder(path string) (folder SharedFolder, err error) {
+	params := map[string]interface{}{"path": path, "force_async": false}
+	body, err := c.rpcRaw("/sharing/share_folder", params)
+	if err != nil {
+		return
+	}
+	body, err = c.waitJob("/sharing/check_share_job_status", body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(body, &folder)
+	return
+}
+
+// UnshareFolder stops sharing a folder, optionally leaving members a copy
+func (c *Client) UnshareFolder(id string, leaveCopy bool) error {
+	params := map[string]interface{}{"shared_folder_id": id, "leave_a_copy": leaveCopy}
+	body, err := c.rpcRaw("/sharing/unshare_folder", params)
+	if err != nil {
+		return err
+	}
+	_, err = c.waitJob("/sharing/check_job_status", body)
+	return err
+}
+
+// SharedFolders lists all the shared folders the user has access to
+func (c *Client) SharedFolders() ([]SharedFolder, error) {
+	var folders []SharedFolder
+	var res sharedFolders
+	err := c.rpc("/sharing/list_folders", map[string]int{"limit": 1000}, &res)
+	for err == nil {
+		folders = append(folders, res.Entries...)
+		if res.Cursor == "" {
+			break
+		}
+		cursor := res.Cursor
+		res = sharedFolders{}
+		err = c.rpc("/sharing/list_folders/continue", map[string]string{"cursor": cursor}, &res)
+	}
+	return folders, err
+}
+
+// SharedFolderId resolves a shared folder id from either an id or the
+// path of a mounted shared folder
+func (c *Client) SharedFolderId(pathOrId string) (string, error) {
+	if !strings.Contains(pathOrId, "/") {
+		return pathOrId, nil
+	}
+	folders, err := c.SharedFolders()
+	if err != nil {
+		return "", err
+	}
+	for _, f := range folders {
+		if f.PathLower == strings.ToLower(pathOrId) {
+			return f.SharedFolderId, nil
+		}
+	}
+	return "", fmt.Errorf("error: %q is not a shared folder", pathOrId)
+}
+
+// FolderMembers lists the members and pending invitees of a shared folder
+func (c *Client) FolderMembers(id string) ([]Member, error) {
+	var members []Member
+	var res folderMembers
+	params := map[string]interface{}{"shared_folder_id": id, "limit": 1000}
+	err := c.rpc("/sharing/list_folder_members", params, &res)
+	for err == nil {
+		members = append(members, res.Users...)
+		members = append(members, res.Invitees...)
+		if res.Cursor == "" {
+			break
+		}
+		cursor := res.Cursor
+		res = folderMembers{}
+		err = c.rpc("/sharing/list_folder_members/continue", map[string]string{"cursor": cursor}, &res)
+	}
+	return members, err
+}
+
+// AddMembers invites emails to a shared folder with the given access level
+func (c *Client) AddMembers(id, level string, emails []string, quiet bool) error {
+	if !isAccessLevel(level) {
+		return fmt.Errorf("error: invalid access level %q, use one of %s", level, strings.Join(AccessLevels, ", "))
+	}
+	var members []addMember
+	for _, e := range emails {
+		m := addMember{memberSelector{"email", e}, Tagged{level}}
+		members = append(members, m)
+	}
+	params := map[string]interface{}{"shared_folder_id": id, "members": members, "quiet": quiet}
+	return c.rpc("/sharing/add_folder_member", params, nil)
+}
+
+// UpdateMember changes the access level of a member of a shared folder
+func (c *Client) UpdateMember(id, email, level string) error {
+	if !isAccessLevel(level) {
+		return fmt.Errorf("error: invalid access level %q, use one of %s", level, strings.Join(AccessLevels, ", "))
+	}
+	params := map[string]interface{}{
+		"shared_folder_id": id,
+		"member": memberSelector{"email", email},
+		"access_level": level,
+	}
+	return c.rpc("/sharing/update_folder_member", params, nil)
+}
+
+// RemoveMember removes a member from a shared folder, waiting for the job to complete
+func (c *Client) RemoveMember(id, email string, leaveCopy bool) error {
+	params := map[string]interface{}{
+		"shared_folder_id": id,
+		"member": memberSelector{"email", email},
+		"leave_a_copy": leaveCopy,
+	}
+	body, err := c.rpcRaw("/sharing/remove_folder_member", params)
+	if err != nil {
+		return err
+	}
+	_, err = c.waitJob("/sharing/check_remove_member_job_status", body)
+	return err
+}
+
+// MountFolder mounts a shared folder the user has been invited to
+func (c *Client) MountFolder(id string) (folder SharedFolder, err error) {
+	err = c.rpc("/sharing/mount_folder", map[string]string{"shared_folder_id": id}, &folder)
+	return
+}
+
+// UnmountFolder removes a shared folder from the user's Dropbox
+func (c *Client) UnmountFolder(id string) error {
+	return c.rpc("/sharing/unmount_folder", map[string]string{"shared_folder_id": id}, nil)
+}
+
+func (f SharedFolder) Fields() []string {
+	return []string{"shared_folder_id", "access_type", "path", "name", "mounted"}
+}
+
+func (f SharedFolder) Values() []string {
+	return []string{f.SharedFolderId, f.AccessType.Tag, f.PathLower, f.Name, strconv.FormatBool(f.Mounted())}
+}
+
+func (m Member) Fields() []string {
+	return []string{"email", "access_type", "name", "invited", "inherited"}
+}
+
+func (m Member) Values() []string {
+	return []string{m.Email(), m.AccessType.Tag, m.User.DisplayName, strconv.FormatBool(m.User.Email == ""), strconv.FormatBool(m.IsInherited)}
+}
+
+func PrintSharedFolders(folders []SharedFolder) {
+	if Structured() {
+		rows := make([]Row, len(folders))
+		for k, f := range folders {
+			rows[k] = f
+		}
+		Emit(rows)
+		return
+	}
+	for _, f := range folders {
+		path := f.PathLower
+		if !f.Mounted() {
+			path = "(not mounted) " + f.Name
+		}
+		fmt.Printf("%-14s %-8s %s\n", f.SharedFolderId, f.AccessType.Tag, path)
+	}
+}
+
+func PrintMembers(members []Member) {
+	if Structured() {
+		rows := make([]Row, len(members))
+		for k, m := range members {
+			rows[k] = m
+		}
+		Emit(rows)
+		return
+	}
+	for _, m := range members {
+		name := m.User.DisplayName
+		if m.User.Email == "" {
+			name = "(invited)"
+		}
+		fmt.Printf("%-40s %-18s %s\n", m.Email(), m.AccessType.Tag, name)
+	}
+}
@@ -0,0 +1,545 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"sync"
+	"bytes"
+	"strings"
+	"strconv"
+	"unicode/utf16"
+	"encoding/binary"
+	pth "path"
+)
+
+// bytes fetched from the start of each file to read its tags; the tail or
+// other parts are only fetched when the tags do not fit
+var TagHeadSize = int64(64*1024)
+
+// moov atoms bigger than this are not read
+const maxAtomSize = 16*1024*1024
+
+// the audio files whose tags ReadTags can parse
+var TagExtensions = []string{".mp3", ".flac", ".m4a", ".m4b"}
+
+// HasTags reports whether name is an audio file in one of TagExtensions
+func HasTags(name string) bool {
+	ext := strings.ToLower(pth.Ext(name))
+	for _, e := range TagExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+type Tags struct {
+		Artist   string  `json:"artist,omitempty"`
+		Album    string  `json:"album,omitempty"`
+		Title    string  `json:"title,omitempty"`
+		Track    int     `json:"track,omitempty"`
+		Duration float64 `json:"duration,omitempty"` // seconds
+}
+
+// String returns "artist - title (m:ss)" with the known parts
+func (t Tags) String() string {
+	s := t.Title
+	if t.Artist != "" {
+		s = t.Artist + " - " + s
+	}
+	if t.Duration > 0 {
+		d := int(t.Duration + 0.5)
+		s += fmt.Sprintf(" (%d:%02d)", d/60, d%60)
+	}
+	return strings.TrimSpace(s)
+}
+
+// tagReader reads parts of a remote file, from its head when possible
+type tagReader struct {
+		c    *Client
+		path string
+		size int64
+		head []byte
+}
+
+func (r *tagReader) read(off, n int64) ([]byte, error) {
+	if off < 0 || off >= r.size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if off+n > r.size {
+		n = r.size - off
+	}
+	if off+n <= int64(len(r.head)) {
+		return r.head[off:off+n], nil
+	}
+	b, err := r.c.ReadRange(r.path, off, off+n-1)
+	if err == nil && int64(len(b)) < n {
+		err = io.ErrUnexpectedEOF
+	}
+	return b, err
+}
+
+// ReadTags reads the artist, album, title, track number and duration of
+// the audio file e from ID3 tags and MPEG headers, FLAC metadata blocks
+// or MP4 atoms, with range requests
+func (c *Client) ReadTags(e Entry) (Tags, error) {
+	n := TagHeadSize
+	if e.Size < n {
+		n = e.Size
+	}
+	if n == 0 {
+		return Tags{}, nil
+	}
+	head, err := c.ReadRange(e.PathDisplay, 0, n-1)
+	if err != nil {
+		return Tags{}, err
+	}
+	r := &tagReader{c, e.PathDisplay, e.Size, head}
+	switch {
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		return r.flac()
+	case len(head) >= 8 && string(head[4:8]) == "ftyp":
+		return r.mp4()
+	case bytes.HasPrefix(head, []byte("ID3")), len(head) > 1 && head[0] == 0xFF && head[1]&0xE0 == 0xE0:
+		return r.mp3()
+	}
+	return Tags{}, fmt.Errorf("error: %s: unsupported audio format", e.PathDisplay)
+}
+
+// ReadAllTags reads the tags of the audio files among entries that HasTags,
+// Parallelism at a time, by path_lower; failures are reported on stderr
+func (c *Client) ReadAllTags(entries Entries) map[string]Tags {
+	tags := map[string]Tags{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	limit := Parallelism
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan bool, limit)
+	for _, e := range entries {
+		if e.Tag != "file" || !HasTags(e.Name) {
+			continue
+		}
+		wg.Add(1)
+		go func(e Entry) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			t, err := c.ReadTags(e)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return
+			}
+			mu.Lock()
+			tags[e.PathLower] = t
+			mu.Unlock()
+		}(e)
+	}
+	wg.Wait()
+	return tags
+}
+
+// ID3v2 and MPEG audio
+
+func syncsafe(b []byte) int64 {
+	return int64(b[0])<<21 | int64(b[1])<<14 | int64(b[2])<<7 | int64(b[3])
+}
+
+// id3Text decodes a text frame according to its encoding byte
+func id3Text(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	enc, b := b[0], b[1:]
+	var s string
+	switch enc {
+	case 1, 2:
+		bigEndian := enc == 2
+		if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+			bigEndian, b = true, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+			bigEndian, b = false, b[2:]
+		}
+		u := make([]uint16, len(b)/2)
+		for k := range u {
+			if bigEndian {
+				u[k] = binary.BigEndian.Uint16(b[2*k:])
+			} else {
+				u[k] = binary.LittleEndian.Uint16(b[2*k:])
+			}
+		}
+		s = string(utf16.Decode(u))
+	case 3:
+		s = string(b)
+	default:
+		s = latin1(b)
+	}
+	// several values are separated by nulls, keep the first one
+	return strings.TrimSpace(strings.Split(s, "\x00")[0])
+}
+
+func latin1(b []byte) string {
+	r := make([]rune, len(b))
+	for k, c := range b {
+		r[k] = rune(c)
+	}
+	return string(r)
+}
+
+// trackNumber parses "3" or "3/12"
+func trackNumber(s string) int {
+	n, _ := strconv.Atoi(strings.TrimSpace(strings.Split(s, "/")[0]))
+	return n
+}
+
+func (r *tagReader) mp3() (t Tags, err error) {
+	var end int64
+	if bytes.HasPrefix(r.head, []byte("ID3")) && len(r.head) >= 10 {
+		end, err = r.id3v2(&t)
+		if err != nil {
+			return
+		}
+	}
+	if t.Title == "" && r.size >= 128 {
+		r.id3v1(&t)
+	}
+	if t.Duration == 0 {
+		t.Duration = r.mpegDuration(end)
+	}
+	return t, nil
+}
+
+// id3v2 reads the text frames of the tag and returns its end offset
+func (r *tagReader) id3v2(t *Tags) (int64, error) {
+	major, flags := r.head[3], r.head[5]
+	end := 10 + syncsafe(r.head[6:10])
+	if flags&0x10 != 0 {
+		end += 10 // footer
+	}
+	off := int64(10)
+	if flags&0x40 != 0 {
+		b, err := r.read(off, 4)
+		if err != nil {
+			return end, err
+		}
+		if major == 4 {
+			off += syncsafe(b)
+		} else {
+			off += 4 + int64(binary.BigEndian.Uint32(b))
+		}
+	}
+	idLen, hdrLen := int64(4), int64(10)
+	if major == 2 {
+		idLen, hdrLen = 3, 6
+	}
+	for k := 0; k < 256 && off+hdrLen <= end; k++ {
+		h, err := r.read(off, hdrLen)
+		if err != nil {
+			return end, err
+		}
+		if h[0] == 0 {
+			break // padding
+		}
+		id := string(h[:idLen])
+		var size int64
+		switch major {
+		case 2:
+			size = int64(h[3])<<16 | int64(h[4])<<8 | int64(h[5])
+		case 4:
+			size = syncsafe(h[4:8])
+		default:
+			size = int64(binary.BigEndian.Uint32(h[4:8]))
+		}
+		off += hdrLen
+		if size <= 0 || off+size > end {
+			break
+		}
+		var field *string
+		switch id {
+		case "TIT2", "TT2":
+			field = &t.Title
+		case "TPE1", "TP1":
+			field = &t.Artist
+		case "TALB", "TAL":
+			field = &t.Album
+		case "TRCK", "TRK", "TLEN", "TLE":
+		default:
+			off += size
+			continue
+		}
+		b, err := r.read(off, size)
+		if err != nil {
+			return end, err
+		}
+		off += size
+		switch {
+		case field != nil:
+			*field = id3Text(b)
+		case id == "TRCK" || id == "TRK":
+			t.Track = trackNumber(id3Text(b))
+		default:
+			ms, _ := strconv.Atoi(id3Text(b))
+			t.Duration = float64(ms) / 1000
+		}
+	}
+	return end, nil
+}
+
+// id3v1 reads the 128 bytes tag at the end of the file, if any
+func (r *tagReader) id3v1(t *Tags) {
+	b, err := r.read(r.size-128, 128)
+	if err != nil || string(b[:3]) != "TAG" {
+		return
+	}
+	field := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(latin1(b), "\x00"))
+	}
+	t.Title, t.Artist, t.Album = field(b[3:33]), field(b[33:63]), field(b[63:93])
+	if b[125] == 0 && b[126] != 0 {
+		t.Track = int(b[126])
+	}
+}
+
+var(
+	mpeg1Bitrates = []int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}
+	mpeg2Bitrates = []int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}
+	mpegRates     = []int{44100, 48000, 32000}
+)
+
+// mpegDuration computes the duration of layer III audio from the Xing or
+// VBRI header of the first frame after off, or from its bitrate for CBR
+func (r *tagReader) mpegDuration(off int64) float64 {
+	b, err := r.read(off, 4096)
+	if err != nil && len(b) == 0 {
+		return 0
+	}
+	for k := 0; k+4 <= len(b); k++ {
+		if b[k] != 0xFF || b[k+1]&0xE0 != 0xE0 {
+			continue
+		}
+		version, layer := (b[k+1]>>3)&3, (b[k+1]>>1)&3
+		bi, ri := int(b[k+2]>>4), int((b[k+2]>>2)&3)
+		if version == 1 || layer != 1 || bi == 0 || bi == 15 || ri == 3 {
+			continue
+		}
+		mono := b[k+3]>>6 == 3
+		// the Xing header follows the side information
+		rate, spf, bitrate, side := mpegRates[ri], 1152, mpeg1Bitrates[bi], 32
+		if mono {
+			side = 17
+		}
+		if version != 3 {
+			rate, spf, bitrate, side = rate/2, 576, mpeg2Bitrates[bi], 17
+			if mono {
+				side = 9
+			}
+			if version == 0 {
+				rate /= 2
+			}
+		}
+		frame := b[k:]
+		if x := 4 + side; len(frame) >= x+12 && (string(frame[x:x+4]) == "Xing" || string(frame[x:x+4]) == "Info") {
+			if binary.BigEndian.Uint32(frame[x+4:])&1 != 0 {
+				return float64(binary.BigEndian.Uint32(frame[x+8:])) * float64(spf) / float64(rate)
+			}
+		}
+		if len(frame) >= 36+18 && string(frame[36:40]) == "VBRI" {
+			return float64(binary.BigEndian.Uint32(frame[50:])) * float64(spf) / float64(rate)
+		}
+		audio := r.size - off - int64(k)
+		if tag, err := r.read(r.size-128, 3); err == nil && string(tag) == "TAG" {
+			audio -= 128 // ID3v1
+		}
+		return float64(audio) * 8 / float64(bitrate*1000)
+	}
+	return 0
+}
+
+// FLAC
+
+func (r *tagReader) flac() (t Tags, err error) {
+	off := int64(4)
+	for {
+		h, err := r.read(off, 4)
+		if err != nil {
+			return t, err
+		}
+		last, typ := h[0]&0x80 != 0, h[0]&0x7F
+		size := int64(h[1])<<16 | int64(h[2])<<8 | int64(h[3])
+		off += 4
+		switch typ {
+		case 0: // STREAMINFO
+			b, err := r.read(off, size)
+			if err != nil || len(b) < 18 {
+				return t, err
+			}
+			rate := int64(b[10])<<12 | int64(b[11])<<4 | int64(b[12])>>4
+			samples := int64(b[13]&0x0F)<<32 | int64(binary.BigEndian.Uint32(b[14:18]))
+			if rate > 0 {
+				t.Duration = float64(samples) / float64(rate)
+			}
+		case 4: // VORBIS_COMMENT
+			b, err := r.read(off, size)
+			if err != nil {
+				return t, err
+			}
+			vorbisComments(b, &t)
+			return t, nil
+		}
+		off += size
+		if last {
+			return t, nil
+		}
+	}
+}
+
+func vorbisComments(b []byte, t *Tags) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		if n < 0 || 4+n > len(b) {
+			return "", false
+		}
+		s := string(b[4:4+n])
+		b = b[4+n:]
+		return s, true
+	}
+	if _, ok := next(); !ok { // vendor
+		return
+	}
+	if len(b) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	for k := 0; k < count; k++ {
+		c, ok := next()
+		if !ok {
+			return
+		}
+		kv := strings.SplitN(c, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch strings.ToUpper(kv[0]) {
+		case "TITLE":
+			t.Title = kv[1]
+		case "ARTIST":
+			t.Artist = kv[1]
+		case "ALBUM":
+			t.Album = kv[1]
+		case "TRACKNUMBER":
+			t.Track = trackNumber(kv[1])
+		}
+	}
+}
+
+// MP4
+
+// atoms calls fn with the type and body of each atom in b
+func atoms(b []byte, fn func(typ string, body []byte)) {
+	for len(b) >= 8 {
+		size := int64(binary.BigEndian.Uint32(b))
+		hdr := int64(8)
+		if size == 1 && len(b) >= 16 {
+			size, hdr = int64(binary.BigEndian.Uint64(b[8:])), 16
+		} else if size == 0 {
+			size = int64(len(b))
+		}
+		if size < hdr || size > int64(len(b)) {
+			return
+		}
+		fn(string(b[4:8]), b[hdr:size])
+		b = b[size:]
+	}
+}
+
+func (r *tagReader) mp4() (t Tags, err error) {
+	// find moov among the top level atoms, often after mdat at the end
+	off := int64(0)
+	for off+8 <= r.size {
+		h, err := r.read(off, 16)
+		if err != nil && len(h) < 8 {
+			return t, err
+		}
+		size := int64(binary.BigEndian.Uint32(h))
+		if size == 1 && len(h) >= 16 {
+			size = int64(binary.BigEndian.Uint64(h[8:]))
+		} else if size == 0 {
+			size = r.size - off
+		}
+		if size < 8 {
+			break
+		}
+		if string(h[4:8]) == "moov" {
+			if size > maxAtomSize {
+				return t, fmt.Errorf("error: %s: moov atom too big", r.path)
+			}
+			b, err := r.read(off, size)
+			if err != nil {
+				return t, err
+			}
+			atoms(b[8:], func(typ string, body []byte) {
+				switch typ {
+				case "mvhd":
+					t.Duration = mvhdDuration(body)
+				case "udta":
+					atoms(body, func(typ string, body []byte) {
+						if typ == "meta" && len(body) > 4 {
+							atoms(body[4:], func(typ string, body []byte) {
+								if typ == "ilst" {
+									ilst(body, &t)
+								}
+							})
+						}
+					})
+				}
+			})
+			return t, nil
+		}
+		off += size
+	}
+	return t, fmt.Errorf("error: %s: no moov atom", r.path)
+}
+
+func mvhdDuration(b []byte) float64 {
+	if len(b) >= 32 && b[0] == 1 {
+		scale, d := binary.BigEndian.Uint32(b[20:]), binary.BigEndian.Uint64(b[24:])
+		if scale > 0 {
+			return float64(d) / float64(scale)
+		}
+	} else if len(b) >= 20 {
+		scale, d := binary.BigEndian.Uint32(b[12:]), binary.BigEndian.Uint32(b[16:])
+		if scale > 0 {
+			return float64(d) / float64(scale)
+		}
+	}
+	return 0
+}
+
+func ilst(b []byte, t *Tags) {
+	atoms(b, func(item string, body []byte) {
+		atoms(body, func(typ string, data []byte) {
+			if typ != "data" || len(data) < 8 {
+				return
+			}
+			v := data[8:]
+			switch item {
+			case "\xa9nam":
+				t.Title = string(v)
+			case "\xa9ART":
+				t.Artist = string(v)
+			case "\xa9alb":
+				t.Album = string(v)
+			case "trkn":
+				if len(v) >= 4 {
+					t.Track = int(binary.BigEndian.Uint16(v[2:]))
+				}
+			}
+		})
+	})
+}
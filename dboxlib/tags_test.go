@@ -0,0 +1,195 @@
+package dboxlib
+
+import(
+	"bytes"
+	"math"
+	"testing"
+	"encoding/binary"
+)
+
+func be32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+// fileReader reads the whole file from its head, without any request
+func fileReader(b []byte) *tagReader {
+	return &tagReader{path: "test", size: int64(len(b)), head: b}
+}
+
+func id3Frame(id, text string) []byte {
+	b := append([]byte(id), be32(uint32(len(text)+1))...)
+	b = append(b, 0, 0, 3) // flags, utf-8
+	return append(b, text...)
+}
+
+func id3v23(frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 16)...) // padding
+	n := len(body)
+	return append([]byte{'I', 'D', '3', 3, 0, 0, byte(n>>21&0x7F), byte(n>>14&0x7F), byte(n>>7&0x7F), byte(n&0x7F)}, body...)
+}
+
+// mpegFrame is a 417 bytes MPEG-1 layer III frame, 128 kbps, 44.1 kHz,
+// stereo, with a Xing header giving the frame count if frames > 0
+func mpegFrame(frames uint32) []byte {
+	b := make([]byte, 417)
+	copy(b, []byte{0xFF, 0xFB, 0x90, 0x00})
+	if frames > 0 {
+		copy(b[36:], "Xing")
+		binary.BigEndian.PutUint32(b[40:], 1)
+		binary.BigEndian.PutUint32(b[44:], frames)
+	}
+	return b
+}
+
+func id3v1(title, artist, album string, track byte) []byte {
+	b := make([]byte, 128)
+	copy(b, "TAG")
+	copy(b[3:], title)
+	copy(b[33:], artist)
+	copy(b[63:], album)
+	b[126] = track
+	return b
+}
+
+func flacFile(samples uint64, rate uint32, comments ...string) []byte {
+	info := make([]byte, 34)
+	info[10], info[11], info[12] = byte(rate>>12), byte(rate>>4), byte(rate<<4)|0x02
+	info[13] = 0x10 | byte(samples>>32&0x0F)
+	binary.BigEndian.PutUint32(info[14:], uint32(samples))
+	vc := append(le32(4), "test"...)
+	vc = append(vc, le32(uint32(len(comments)))...)
+	for _, c := range comments {
+		vc = append(vc, le32(uint32(len(c)))...)
+		vc = append(vc, c...)
+	}
+	block := func(typ byte, body []byte) []byte {
+		return append([]byte{typ, byte(len(body)>>16), byte(len(body)>>8), byte(len(body))}, body...)
+	}
+	b := append([]byte("fLaC"), block(0, info)...)
+	return append(b, block(0x84, vc)...)
+}
+
+func atom(typ string, body ...[]byte) []byte {
+	b := bytes.Join(body, nil)
+	return bytes.Join([][]byte{be32(uint32(8+len(b))), []byte(typ), b}, nil)
+}
+
+func mp4File(scale, duration uint32, title, artist string, track uint16) []byte {
+	mvhd := make([]byte, 100)
+	binary.BigEndian.PutUint32(mvhd[12:], scale)
+	binary.BigEndian.PutUint32(mvhd[16:], duration)
+	data := func(v []byte) []byte {
+		return atom("data", make([]byte, 8), v)
+	}
+	trkn := []byte{0, 0, byte(track>>8), byte(track), 0, 12, 0, 0}
+	ilst := atom("ilst",
+		atom("\xa9nam", data([]byte(title))),
+		atom("\xa9ART", data([]byte(artist))),
+		atom("trkn", data(trkn)))
+	moov := atom("moov", atom("mvhd", mvhd), atom("udta", atom("meta", make([]byte, 4), atom("hdlr", make([]byte, 25)), ilst)))
+	// moov after mdat, as often
+	return bytes.Join([][]byte{atom("ftyp", []byte("M4A "), make([]byte, 4)), atom("mdat", make([]byte, 1000)), moov}, nil)
+}
+
+func TestReadTagsFormats(t *testing.T) {
+	cbr := bytes.Repeat(mpegFrame(0), 40)
+	tests := []struct {
+			name  string
+			file  []byte
+			parse func(r *tagReader) (Tags, error)
+			want  Tags
+	}{
+		{"id3v2 + xing", append(id3v23(id3Frame("TIT2", "Song"), id3Frame("TPE1", "Band"), id3Frame("TALB", "Album"), id3Frame("TRCK", "3/12")), mpegFrame(1000)...),
+			(*tagReader).mp3, Tags{Artist: "Band", Album: "Album", Title: "Song", Track: 3, Duration: 1000 * 1152.0 / 44100}},
+		{"id3v2 TLEN", append(id3v23(id3Frame("TIT2", "Song"), id3Frame("TLEN", "61500")), mpegFrame(1000)...),
+			(*tagReader).mp3, Tags{Title: "Song", Duration: 61.5}},
+		{"id3v1 + cbr", append(cbr, id3v1("Old", "Timer", "Best of", 7)...),
+			(*tagReader).mp3, Tags{Artist: "Timer", Album: "Best of", Title: "Old", Track: 7, Duration: float64(len(cbr)) * 8 / 128000}},
+		{"flac", flacFile(441000, 44100, "TITLE=Flac song", "artist=Flac band", "ALBUM=LP", "TRACKNUMBER=02"),
+			(*tagReader).flac, Tags{Artist: "Flac band", Album: "LP", Title: "Flac song", Track: 2, Duration: 10}},
+		{"mp4", mp4File(1000, 95250, "M4a song", "M4a band", 5),
+			(*tagReader).mp4, Tags{Artist: "M4a band", Title: "M4a song", Track: 5, Duration: 95.25}},
+	}
+	for _, tt := range tests {
+		got, err := tt.parse(fileReader(tt.file))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if math.Abs(got.Duration-tt.want.Duration) > 0.001 {
+			t.Errorf("%s: duration %.3f, want %.3f", tt.name, got.Duration, tt.want.Duration)
+		}
+		got.Duration, tt.want.Duration = 0, 0
+		if got != tt.want {
+			t.Errorf("%s: got %#v, want %#v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMp4WithoutMoov(t *testing.T) {
+	b := append(atom("ftyp", []byte("M4A "), make([]byte, 4)), atom("mdat", make([]byte, 100))...)
+	if _, err := fileReader(b).mp4(); err == nil {
+		t.Error("mp4 without moov: no error")
+	}
+}
+
+func TestId3Text(t *testing.T) {
+	tests := []struct {
+			b    []byte
+			want string
+	}{
+		{nil, ""},
+		{[]byte("\x00Caf\xe9"), "Café"},
+		{[]byte("\x03Café\x00second"), "Café"},
+		{[]byte("\x01\xff\xfeC\x00a\x00f\x00\xe9\x00"), "Café"},
+		{[]byte("\x01\xfe\xff\x00C\x00a\x00f\x00\xe9"), "Café"},
+		{[]byte("\x02\x00C\x00a\x00f\x00\xe9"), "Café"},
+		{[]byte("\x00  padded  "), "padded"},
+	}
+	for _, tt := range tests {
+		if got := id3Text(tt.b); got != tt.want {
+			t.Errorf("id3Text(%q) = %q, want %q", tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestTrackNumber(t *testing.T) {
+	for s, want := range map[string]int{"3": 3, "03/12": 3, " 7 / 9": 7, "": 0, "A1": 0} {
+		if got := trackNumber(s); got != want {
+			t.Errorf("trackNumber(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+func TestHasTags(t *testing.T) {
+	for name, want := range map[string]bool{"a.mp3": true, "B.FLAC": true, "c.m4a": true, "d.ogg": false, "e.wav": false, "f.opus": false, "g": false} {
+		if got := HasTags(name); got != want {
+			t.Errorf("HasTags(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestTagsString(t *testing.T) {
+	tests := []struct {
+			t    Tags
+			want string
+	}{
+		{Tags{Artist: "A", Title: "T", Duration: 61.6}, "A - T (1:02)"},
+		{Tags{Title: "T"}, "T"},
+		{Tags{Duration: 5}, "(0:05)"},
+	}
+	for _, tt := range tests {
+		if got := tt.t.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.t, got, tt.want)
+		}
+	}
+}
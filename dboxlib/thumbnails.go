@@ -0,0 +1,265 @@
+package dboxlib
+
+import(
+	"os"
+	"fmt"
+	"sort"
+	"time"
+	"bytes"
+	"strings"
+	"strconv"
+	"image"
+	"image/draw"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io/ioutil"
+	"net/http"
+	"encoding/json"
+	"encoding/base64"
+	pth "path"
+	ospath "path/filepath"
+)
+
+var(
+	ThumbnailSizes   = []string{"w32h32", "w64h64", "w128h128", "w256h256", "w480h320", "w640h480", "w960h640", "w1024h768", "w2048h1536"}
+	ThumbnailFormats = []string{"jpeg", "png"}
+	ThumbnailModes   = []string{"strict", "bestfit", "fitone_bestfit"}
+	// pause before retrying a batch refused for too many requests, doubled each time
+	ThumbnailPause = 2 * time.Second
+)
+
+// get_thumbnail_batch takes at most 25 files per call
+const thumbnailBatch = 25
+
+type ThumbnailOptions struct {
+		Size   string `json:"size"`   // one of ThumbnailSizes
+		Format string `json:"format"` // one of ThumbnailFormats
+		Mode   string `json:"mode"`   // one of ThumbnailModes
+}
+
+func (o ThumbnailOptions) withDefaults() ThumbnailOptions {
+	if o.Size == "" {
+		o.Size = "w256h256"
+	}
+	if o.Format == "" {
+		o.Format = "jpeg"
+	}
+	if o.Mode == "" {
+		o.Mode = "strict"
+	}
+	return o
+}
+
+// Ext returns the file extension of the thumbnails
+func (o ThumbnailOptions) Ext() string {
+	if o.Format == "png" {
+		return ".png"
+	}
+	return ".jpg"
+}
+
+// Dimensions returns the width and height of o.Size
+func (o ThumbnailOptions) Dimensions() (w, h int) {
+	s := strings.TrimPrefix(o.withDefaults().Size, "w")
+	wh := strings.SplitN(s, "h", 2)
+	if len(wh) == 2 {
+		w, _ = strconv.Atoi(wh[0])
+		h, _ = strconv.Atoi(wh[1])
+	}
+	return
+}
+
+type thumbnailArg struct {
+		Resource struct {
+				Tag  string `json:".tag"`
+				Path string `json:"path"`
+		} `json:"resource"`
+		ThumbnailOptions
+}
+
+// Thumbnail returns the thumbnail of the image at path and its metadata
+func (c *Client) Thumbnail(path string, opts ThumbnailOptions) ([]byte, Meta, error) {
+	var meta Meta
+	arg := thumbnailArg{ThumbnailOptions: opts.withDefaults()}
+	arg.Resource.Tag, arg.Resource.Path = "path", path
+	resp, err := c.openContent("/files/get_thumbnail_v2", arg)
+	if err != nil {
+		return nil, meta, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, meta, err
+	}
+	var res struct {
+			FileMetadata Meta `json:"file_metadata"`
+	}
+	if err = json.Unmarshal([]byte(resp.Header.Get("Dropbox-API-Result")), &res); err != nil {
+		return data, meta, fmt.Errorf("error: bad Dropbox-API-Result header: %v", err)
+	}
+	return data, res.FileMetadata, nil
+}
+
+type ThumbnailResult struct {
+		Path  string
+		Data  []byte
+		Error string
+}
+
+// ThumbnailBatch returns the thumbnails of the images at paths, 25 per
+// request, pausing and retrying when the server asks to slow down
+func (c *Client) ThumbnailBatch(paths []string, opts ThumbnailOptions) ([]ThumbnailResult, error) {
+	opts = opts.withDefaults()
+	// get_thumbnail_batch is an rpc endpoint on the content host
+	content := *c
+	content.BaseUrl = content_url
+	var results []ThumbnailResult
+	for start := 0; start < len(paths); start += thumbnailBatch {
+		end := start + thumbnailBatch
+		if end > len(paths) {
+			end = len(paths)
+		}
+		type entry struct {
+				Path string `json:"path"`
+				ThumbnailOptions
+		}
+		var entries []entry
+		for _, p := range paths[start:end] {
+			entries = append(entries, entry{p, opts})
+		}
+		var res struct {
+				Entries []struct {
+						Tag       string          `json:".tag"`
+						Thumbnail string          `json:"thumbnail"`
+						Failure   json.RawMessage `json:"failure"`
+				} `json:"entries"`
+		}
+		var err error
+		for pause := ThumbnailPause; ; pause *= 2 {
+			err = content.rpc("/files/get_thumbnail_batch", map[string]interface{}{"entries": entries}, &res)
+			if !tooManyRequests(err) || pause > 16*ThumbnailPause {
+				break
+			}
+			fmt.Printf("too many requests, retrying in %s\n", pause)
+			time.Sleep(pause)
+		}
+		if err != nil {
+			return results, err
+		}
+		for k, e := range res.Entries {
+			r := ThumbnailResult{Path: paths[start+k]}
+			if e.Tag == "success" {
+				r.Data, err = base64.StdEncoding.DecodeString(e.Thumbnail)
+				if err != nil {
+					r.Error = err.Error()
+				}
+			} else {
+				r.Error = string(e.Failure)
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// tooManyRequests reports whether err asks to slow down
+func tooManyRequests(err error) bool {
+	return StatusCode(err) == http.StatusTooManyRequests || HasErrorTag(err, "too_many_requests")
+}
+
+// Preview returns a PDF or HTML preview of the document at path, and its
+// content type
+func (c *Client) Preview(path string) ([]byte, string, error) {
+	resp, err := c.openContent("/files/get_preview", map[string]string{"path": path})
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, resp.Header.Get("Content-Type"), err
+}
+
+// ExportThumbnails saves the thumbnails of the images in path, or below it
+// if recursive, into localDir, keeping their folder structure. It returns
+// the thumbnails in the listing order.
+func (c *Client) ExportThumbnails(path, localDir string, recursive bool, opts ThumbnailOptions) ([]ThumbnailResult, error) {
+	opts = opts.withDefaults()
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: recursive})
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(ByPathLower(entries))
+	var paths []string
+	for _, e := range entries {
+		if e.Tag == "file" && c.Wanted(e, "image") {
+			paths = append(paths, e.PathDisplay)
+		}
+	}
+	results, err := c.ThumbnailBatch(paths, opts)
+	if err != nil {
+		return results, err
+	}
+	base := len(splitPath(path))
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Println(r.Path, "failed:", r.Error)
+			continue
+		}
+		rel := splitPath(r.Path)[base:]
+		localPath := ospath.Join(append([]string{localDir}, rel...)...)
+		localPath = strings.TrimSuffix(localPath, pth.Ext(localPath)) + opts.Ext()
+		if err := os.MkdirAll(ospath.Dir(localPath), 0777); err != nil {
+			return results, err
+		}
+		if err := ioutil.WriteFile(localPath, r.Data, 0666); err != nil {
+			return results, err
+		}
+		fmt.Println(localPath)
+	}
+	return results, nil
+}
+
+// ContactSheet lays out the thumbnails in a grid of cells of w x h pixels,
+// columns wide, and writes it as a JPEG to localPath
+func ContactSheet(thumbs []ThumbnailResult, w, h, columns int, localPath string) error {
+	var images []image.Image
+	for _, t := range thumbs {
+		if t.Error != "" {
+			continue
+		}
+		img, _, err := image.Decode(bytes.NewReader(t.Data))
+		if err != nil {
+			fmt.Println(t.Path, err)
+			continue
+		}
+		images = append(images, img)
+	}
+	if len(images) == 0 {
+		return fmt.Errorf("error: no thumbnails for a contact sheet")
+	}
+	if columns <= 0 {
+		columns = 8
+	}
+	if columns > len(images) {
+		columns = len(images)
+	}
+	const gap = 4
+	rows := (len(images) + columns - 1) / columns
+	sheet := image.NewRGBA(image.Rect(0, 0, columns*(w+gap)+gap, rows*(h+gap)+gap))
+	draw.Draw(sheet, sheet.Bounds(), &image.Uniform{color.Gray{32}}, image.Point{}, draw.Src)
+	for k, img := range images {
+		b := img.Bounds()
+		cell := image.Rect(0, 0, w, h).Add(image.Pt(gap + (k%columns)*(w+gap), gap + (k/columns)*(h+gap)))
+		// centered in its cell, cropped if bigger
+		at := cell.Min.Add(image.Pt((w-b.Dx())/2, (h-b.Dy())/2))
+		dst := b.Sub(b.Min).Add(at).Intersect(cell)
+		draw.Draw(sheet, dst, img, b.Min.Add(dst.Min.Sub(at)), draw.Over)
+	}
+	out, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	return jpeg.Encode(out, sheet, &jpeg.Options{Quality: 90})
+}
@@ -0,0 +1,102 @@
+package dboxlib
+
+import(
+	"os"
+	"bytes"
+	"errors"
+	"testing"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	ospath "path/filepath"
+)
+
+func TestThumbnailDimensions(t *testing.T) {
+	tests := []struct {
+			size string
+			w, h int
+	}{
+		{"", 256, 256},
+		{"w64h64", 64, 64},
+		{"w1024h768", 1024, 768},
+		{"bad", 0, 0},
+	}
+	for _, tt := range tests {
+		if w, h := (ThumbnailOptions{Size: tt.size}).Dimensions(); w != tt.w || h != tt.h {
+			t.Errorf("Dimensions(%q) = %d, %d, want %d, %d", tt.size, w, h, tt.w, tt.h)
+		}
+	}
+	if ext := (ThumbnailOptions{Format: "png"}).Ext(); ext != ".png" {
+		t.Errorf("png Ext() = %q", ext)
+	}
+	if ext := (ThumbnailOptions{}).Ext(); ext != ".jpg" {
+		t.Errorf("default Ext() = %q", ext)
+	}
+}
+
+func TestTooManyRequests(t *testing.T) {
+	tests := []struct {
+			err  error
+			want bool
+	}{
+		{nil, false},
+		{&APIError{StatusCode: 429, Status: "429 Too Many Requests"}, true},
+		{&APIError{StatusCode: 409, Summary: "too_many_requests/.."}, true},
+		{&APIError{StatusCode: 409, Summary: "path/not_found/429"}, false},
+		{errors.New("error: 429"), false},
+	}
+	for _, tt := range tests {
+		if got := tooManyRequests(tt.err); got != tt.want {
+			t.Errorf("tooManyRequests(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func pngThumb(w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	var b bytes.Buffer
+	png.Encode(&b, img)
+	return b.Bytes()
+}
+
+func TestContactSheet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := ospath.Join(dir, "sheet.jpg")
+	thumbs := []ThumbnailResult{
+		{Path: "/a.jpg", Data: pngThumb(32, 24)},
+		{Path: "/b.jpg", Error: "unsupported_image"},
+		{Path: "/c.jpg", Data: []byte("not an image")},
+		{Path: "/d.jpg", Data: pngThumb(64, 64)}, // bigger than a cell, cropped
+		{Path: "/e.jpg", Data: pngThumb(10, 32)},
+	}
+	if err = ContactSheet(thumbs, 32, 32, 2, out); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sheet, err := jpeg.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 images on 2 columns, 4 pixels gaps
+	if b := sheet.Bounds(); b.Dx() != 2*36+4 || b.Dy() != 2*36+4 {
+		t.Errorf("contact sheet of %dx%d, want 76x76", b.Dx(), b.Dy())
+	}
+	if err = ContactSheet(thumbs[1:3], 32, 32, 2, out); err == nil {
+		t.Error("contact sheet without any image: no error")
+	}
+}
@@ -0,0 +1,179 @@
+package dboxlib
+
+import(
+	"os"
+	"fmt"
+	"sort"
+	"sync"
+	"strings"
+	pth "path"
+	"github.com/xiconet/utils"
+)
+
+type TrashEntry struct {
+		Entry
+		Deleted string // server_deleted time, empty for deleted folders
+		LastRev Meta   // last revision before deletion
+}
+
+type ByDeleted []TrashEntry
+
+func (a ByDeleted) Len() int           { return len(a) }
+func (a ByDeleted) Swap(i, j int)      { a[i], a[j] = a[j], a[i] }
+func (a ByDeleted) Less(i, j int) bool { return a[i].Deleted > a[j].Deleted }
+
+// Trash lists the deleted entries under path, most recently deleted first.
+// The last revision of each deleted file is looked up, Parallelism at a
+// time; deleted entries with deleted entries below them are folders, which
+// have no revisions, and are not looked up.
+func (c *Client) Trash(path string, recursive bool) ([]TrashEntry, error) {
+	arg := ListFolderArg{Path: path, Recursive: recursive, IncludeDeleted: true}
+	entries, err := c.ListEntries(arg)
+	if err != nil {
+		return nil, err
+	}
+	var trash []TrashEntry
+	folders := map[string]bool{}
+	for _, e := range entries {
+		if e.Tag == "deleted" {
+			e.User = c.User
+			trash = append(trash, TrashEntry{Entry: e})
+			folders[pth.Dir(e.PathLower)] = true
+		}
+	}
+	limit := Parallelism
+	if limit <= 0 {
+		limit = 1
+	}
+	sem := make(chan bool, limit)
+	var wg sync.WaitGroup
+	for k, t := range trash {
+		if folders[t.PathLower] {
+			continue
+		}
+		wg.Add(1)
+		go func(t *TrashEntry) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			revs, err := c.ListRevisions(t.PathDisplay, 1)
+			switch {
+			case err == nil && len(revs.Entries) > 0:
+				t.Deleted = revs.ServerDeleted
+				t.LastRev = revs.Entries[0]
+			case err != nil && StatusCode(err) != 409:
+				// a 409 is expected for the folders that were deleted empty
+				fmt.Fprintln(os.Stderr, t.PathDisplay, err)
+			}
+		}(&trash[k])
+	}
+	wg.Wait()
+	sort.Stable(ByDeleted(trash))
+	return trash, nil
+}
+
+// Undelete restores the deleted files matching pattern to their last
+// revision. With dryRun, it only returns what would be restored.
+func (c *Client) Undelete(pattern string, dryRun bool) (restored Metaset, err error) {
+	trash, err := c.Trash(GlobBase(pattern), true)
+	if err != nil {
+		return
+	}
+	pattern = strings.ToLower(pattern)
+	for _, t := range trash {
+		if !MatchPath(pattern, t.PathLower) || t.LastRev.Rev == "" {
+			continue
+		}
+		if dryRun {
+			restored = append(restored, t.LastRev)
+			continue
+		}
+		meta, err := c.Restore(t.PathDisplay, t.LastRev.Rev)
+		if err != nil {
+			fmt.Printf("error: could not restore %s: %s\n", t.PathDisplay, err)
+			continue
+		}
+		restored = append(restored, meta)
+	}
+	return
+}
+
+// RemovalSummary returns the number of files and bytes that removing path would delete
+func (c *Client) RemovalSummary(path string) (files int, bytes int64, err error) {
+	meta, err := c.GetMetadata(path)
+	if err != nil {
+		return
+	}
+	if meta.Tag != "folder" {
+		return 1, meta.Size, nil
+	}
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: true})
+	for _, e := range entries {
+		if e.Tag == "file" {
+			files += 1
+			bytes += e.Size
+		}
+	}
+	return
+}
+
+// ListFolderDeleted lists path like ListFolder, including deleted entries
+func (c *Client) ListFolderDeleted(path string, opts ListOptions) {
+	entries, err := c.ListEntries(ListFolderArg{Path: path, IncludeDeleted: true})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if Structured() {
+		for k, _ := range entries { entries.SetUser(c.User, k) }
+		SortEntries(entries, opts)
+		Emit(entryRows(entries))
+		return
+	}
+	fmt.Printf("User: %s\n", c.User)
+	for k, e := range entries {
+		if e.Tag == "deleted" && !opts.Long {
+			entries[k].Name += " (deleted)"
+		}
+	}
+	printRes(entries, opts)
+}
+
+// PrintRestored prints the path and revision of the restored files, or of
+// the files that would be restored if dryRun
+func PrintRestored(restored Metaset, dryRun bool) {
+	if Structured() {
+		Emit(metaRows(restored))
+		return
+	}
+	for _, m := range restored {
+		fmt.Println(m.PathDisplay, m.Rev)
+	}
+	if dryRun {
+		fmt.Printf("%d file(s) would be restored\n", len(restored))
+		return
+	}
+	fmt.Printf("%d file(s) restored\n", len(restored))
+}
+
+func PrintTrash(trash []TrashEntry) {
+	if Structured() {
+		var rows []Row
+		for _, t := range trash {
+			r := EntryRecord(t.Entry)
+			r.Rev, r.Size, r.ContentHash = t.LastRev.Rev, t.LastRev.Size, t.LastRev.ContentHash
+			r.ServerModified = t.LastRev.ServerModified
+			rows = append(rows, r)
+		}
+		Emit(rows)
+		return
+	}
+	for _, t := range trash {
+		if t.LastRev.Rev == "" {
+			fmt.Printf("%-20s %-20s %10s  %s/\n", "", "", "", t.PathDisplay)
+			continue
+		}
+		size, _ := utils.NiceBytes(t.LastRev.Size)
+		fmt.Printf("%-20s %-20s %10s  %s\n", t.Deleted, t.LastRev.Rev, size, t.PathDisplay)
+	}
+}
@@ -0,0 +1,83 @@
+package dboxlib
+
+import(
+	"fmt"
+	"github.com/xiconet/utils"
+)
+
+type AccountUsage struct {
+		Account   string  `json:"account"`
+		Email     string  `json:"email"`
+		Used      int64   `json:"used"`
+		Allocated int64   `json:"allocated"`
+		Free      int64   `json:"free"`
+		Percent   float64 `json:"percent"`
+		Error     string  `json:"error,omitempty"`
+}
+
+func accountUsage(user string, info Info, usage SpaceUsage) AccountUsage {
+	u := AccountUsage{
+		Account: user,
+		Email: info.Email,
+		Used: usage.Used,
+		Allocated: usage.Allocation.Allocated,
+		Free: usage.Allocation.Allocated - usage.Used,
+	}
+	if u.Allocated > 0 {
+		u.Percent = float64(u.Used) * 100 / float64(u.Allocated)
+	}
+	return u
+}
+
+// UsageAll fetches the account info and space usage of every account concurrently
+func UsageAll(accounts []string) []AccountUsage {
+	var usages []AccountUsage
+	results := ForEachAccount(accounts, Parallelism, func(d *Client) (interface{}, error) {
+		info, usage, err := d.AccountInfo()
+		return accountUsage(d.User, info, usage), err
+	})
+	for _, r := range results {
+		u := r.Value.(AccountUsage)
+		if r.Err != nil {
+			u = AccountUsage{Account: r.User, Error: r.Err.Error()}
+		}
+		usages = append(usages, u)
+	}
+	return usages
+}
+
+// PrintUsage prints a usage table with totals, or the structured output
+func PrintUsage(usages []AccountUsage) {
+	if Structured() {
+		rows := make([]Row, len(usages))
+		for k, u := range usages {
+			rows[k] = u
+		}
+		Emit(rows)
+		return
+	}
+	var total AccountUsage
+	fmt.Printf("%-12s %-32s %10s %10s %10s %6s\n", "account", "email", "used", "quota", "free", "full")
+	for _, u := range usages {
+		if u.Error != "" {
+			fmt.Printf("%-12s error: %s\n", u.Account, u.Error)
+			continue
+		}
+		printUsageLine(u)
+		total.Used += u.Used
+		total.Allocated += u.Allocated
+		total.Free += u.Free
+	}
+	if total.Allocated > 0 {
+		total.Percent = float64(total.Used) * 100 / float64(total.Allocated)
+	}
+	total.Account = "total"
+	printUsageLine(total)
+}
+
+func printUsageLine(u AccountUsage) {
+	used, _ := utils.NiceBytes(u.Used)
+	quota, _ := utils.NiceBytes(u.Allocated)
+	free, _ := utils.NiceBytes(u.Free)
+	fmt.Printf("%-12s %-32s %10s %10s %10s %5.1f%%\n", u.Account, u.Email, used, quota, free, u.Percent)
+}
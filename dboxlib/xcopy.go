@@ -0,0 +1,241 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"bytes"
+	"strings"
+	"net/http"
+	"io/ioutil"
+	"encoding/json"
+	pth "path"
+)
+
+type CopyReference struct {
+		Metadata      Meta   `json:"metadata"`
+		CopyReference string `json:"copy_reference"`
+		Expires       string `json:"expires"`
+}
+
+// GetCopyReference returns a reference to the file or folder at path that
+// can be saved into another account
+func (c *Client) GetCopyReference(path string) (ref CopyReference, err error) {
+	err = c.rpc("/files/copy_reference/get", map[string]string{"path": path}, &ref)
+	return
+}
+
+// SaveCopyReference saves the content of a copy reference at path
+func (c *Client) SaveCopyReference(ref, path string) (Meta, error) {
+	var res struct {
+			Metadata Meta `json:"metadata"`
+	}
+	err := c.rpc("/files/copy_reference/save", map[string]string{"copy_reference": ref, "path": path}, &res)
+	return res.Metadata, err
+}
+
+// errors of copy references for which CrossCopy streams the files instead
+var copyRefFallback = []string{"no_permission", "not_allowed", "restricted_content", "invalid_copy_reference", "too_many_files"}
+
+func copyRefRefused(err error) bool {
+	for _, tag := range copyRefFallback {
+		if HasErrorTag(err, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// CrossCopy copies srcPath from the src account to dstPath in the dst
+// account. The copy is done server side with a copy reference, falling
+// back to streaming each file from src to dst when that is not allowed.
+// Any other error, e.g. an existing dstPath, is returned: nothing is
+// overwritten nor renamed.
+func CrossCopy(src *Client, srcPath string, dst *Client, dstPath string) (Meta, error) {
+	ref, err := src.GetCopyReference(srcPath)
+	if err == nil {
+		meta, err := dst.SaveCopyReference(ref.CopyReference, dstPath)
+		if err == nil || !copyRefRefused(err) {
+			return meta, err
+		}
+		fmt.Fprintln(os.Stderr, "copy reference refused, streaming instead:", err)
+	} else if copyRefRefused(err) {
+		fmt.Fprintln(os.Stderr, "no copy reference, streaming instead:", err)
+	} else {
+		return Meta{}, err
+	}
+	meta, err := src.GetMetadata(srcPath)
+	if err != nil {
+		return meta, err
+	}
+	if meta.Tag != "folder" {
+		return streamCopy(src, srcPath, dst, dstPath)
+	}
+	entries, err := src.ListEntries(ListFolderArg{Path: srcPath, Recursive: true})
+	if err != nil {
+		return meta, err
+	}
+	depth := len(splitPath(srcPath))
+	for _, e := range entries {
+		rel := splitPath(e.PathDisplay)[depth:]
+		target := pth.Join(append([]string{dstPath}, rel...)...)
+		switch e.Tag {
+		case "folder":
+			if err = dst.rpc("/files/create_folder_v2", map[string]string{"path": target}, nil); err != nil {
+				return meta, err
+			}
+		case "file":
+			if _, err = streamCopy(src, e.PathDisplay, dst, target); err != nil {
+				return meta, err
+			}
+		}
+	}
+	return dst.GetMetadata(dstPath)
+}
+
+// streamCopy pipes the download of a file from src into an upload to dst
+func streamCopy(src *Client, srcPath string, dst *Client, dstPath string) (Meta, error) {
+	fmt.Fprintf(os.Stderr, "streaming %s -> %s\n", srcPath, dstPath)
+	body, err := src.openDownload(srcPath)
+	if err != nil {
+		return Meta{}, err
+	}
+	defer body.Close()
+	return dst.UploadStream(body, dstPath, "add", false)
+}
+
+// Cat writes the content of the file at path to w, e.g. os.Stdout
+func (c *Client) Cat(path string, w io.Writer) (int64, error) {
+	body, err := c.openDownload(path)
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
+
+// openDownload returns the content of the file at path, to be closed by the caller
+func (c *Client) openDownload(path string) (io.ReadCloser, error) {
+	resp, err := c.openContent("/files/download", map[string]string{"path": path})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// openContent calls a content download endpoint with arg as the
+// Dropbox-API-Arg header; the response body is to be closed by the caller
+// and its Dropbox-API-Result header holds the metadata
+func (c *Client) openContent(endpoint string, arg interface{}) (*http.Response, error) {
+	req, err := http.NewRequest("POST", content_url + endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Auth.Token)
+	req.Header.Set("Dropbox-API-Arg", apiArg(arg))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, newAPIError(resp, body)
+	}
+	return resp, nil
+}
+
+// contentRequest posts data to a content endpoint, with arg as the
+// Dropbox-API-Arg header, and returns the response body
+func (c *Client) contentRequest(endpoint string, arg interface{}, data io.Reader) ([]byte, error) {
+	req, err := http.NewRequest("POST", content_url + endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Auth.Token)
+	req.Header.Set("Dropbox-API-Arg", apiArg(arg))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return body, newAPIError(resp, body)
+	}
+	return body, nil
+}
+
+// apiArg encodes arg for the Dropbox-API-Arg header, escaping non-ASCII
+// characters as http headers require
+func apiArg(arg interface{}) string {
+	js, _ := json.Marshal(arg)
+	var b strings.Builder
+	for _, r := range string(js) {
+		if r < 0x80 {
+			b.WriteRune(r)
+		} else if r < 0x10000 {
+			fmt.Fprintf(&b, "\\u%04x", r)
+		} else {
+			// surrogate pair
+			r -= 0x10000
+			fmt.Fprintf(&b, "\\u%04x\\u%04x", 0xd800+(r>>10), 0xdc00+(r&0x3ff))
+		}
+	}
+	return b.String()
+}
+
+// UploadStream uploads everything read from r, e.g. os.Stdin, to remotePath
+// through an upload session, Chunksize bytes at a time, without knowing its
+// size in advance. mode is "add" or "overwrite"; with add and autorename,
+// an existing file is kept and the upload renamed, without autorename it
+// is a conflict.
+func (c *Client) UploadStream(r io.Reader, remotePath, mode string, autorename bool) (meta Meta, err error) {
+	buf := make([]byte, Chunksize)
+	n, err := io.ReadFull(r, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// small enough for a single request
+		body, err := c.contentRequest("/files/upload", map[string]interface{}{"path": remotePath, "mode": mode, "autorename": autorename}, bytes.NewReader(buf[:n]))
+		if err == nil {
+			err = json.Unmarshal(body, &meta)
+		}
+		return meta, err
+	}
+	if err != nil {
+		return
+	}
+	body, err := c.contentRequest("/files/upload_session/start", map[string]bool{"close": false}, bytes.NewReader(buf[:n]))
+	if err != nil {
+		return
+	}
+	var cursor Cursor
+	if err = json.Unmarshal(body, &cursor); err != nil {
+		return
+	}
+	cursor.Offset = int64(n)
+	for {
+		n, err = io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+		params := map[string]interface{}{"cursor": cursor, "close": false}
+		if _, err = c.contentRequest("/files/upload_session/append_v2", params, bytes.NewReader(buf[:n])); err != nil {
+			return
+		}
+		cursor.Offset += int64(n)
+	}
+	commit := map[string]interface{}{"path": remotePath, "mode": mode, "autorename": autorename, "mute": false}
+	params := map[string]interface{}{"cursor": cursor, "commit": commit}
+	body, err = c.contentRequest("/files/upload_session/finish", params, bytes.NewReader(buf[:n]))
+	if err == nil {
+		err = json.Unmarshal(body, &meta)
+	}
+	return
+}
@@ -0,0 +1,42 @@
+package dboxlib
+
+import(
+	"errors"
+	"testing"
+)
+
+func TestCopyRefRefused(t *testing.T) {
+	tests := []struct {
+			err  error
+			want bool
+	}{
+		{&APIError{StatusCode: 409, Summary: "no_permission/.."}, true},
+		{&APIError{StatusCode: 409, Summary: "path/restricted_content/.."}, true},
+		{&APIError{StatusCode: 409, Summary: "too_many_files/."}, true},
+		{&APIError{StatusCode: 409, Summary: "path/conflict/file/.."}, false},
+		{&APIError{StatusCode: 409, Summary: "path/not_found/..."}, false},
+		{&APIError{StatusCode: 409, Summary: "path/no_write_permission/.."}, false},
+		{errors.New("no_permission"), false},
+	}
+	for _, tt := range tests {
+		if got := copyRefRefused(tt.err); got != tt.want {
+			t.Errorf("copyRefRefused(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestApiArg(t *testing.T) {
+	tests := []struct {
+			arg  interface{}
+			want string
+	}{
+		{map[string]string{"path": "/a b"}, `{"path":"/a b"}`},
+		{map[string]string{"path": "/café"}, `{"path":"/caf\u00e9"}`},
+		{map[string]string{"path": "/🎵"}, `{"path":"/\ud83c\udfb5"}`},
+	}
+	for _, tt := range tests {
+		if got := apiArg(tt.arg); got != tt.want {
+			t.Errorf("apiArg(%v) = %s, want %s", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package dboxlib
+
+import(
+	"io"
+	"os"
+	"fmt"
+	"hash"
+	"time"
+	"bytes"
+	"bufio"
+	"errors"
+	"strings"
+	"io/ioutil"
+	"hash/crc32"
+	"compress/flate"
+	"encoding/binary"
+	ospath "path/filepath"
+	"github.com/xiconet/utils"
+)
+
+// download_zip limits
+const(
+	maxZipSize     = 20*1024*1024*1024
+	maxZipFileSize = 4*1024*1024*1024
+	maxZipEntries  = 10000
+)
+
+// ErrZipUnsuitable is returned by DownloadZip for files, for folders over
+// the download_zip limits and when filtering by type, to be downloaded file
+// by file instead
+var ErrZipUnsuitable = errors.New("not a folder within the download_zip limits")
+
+// DownloadZip downloads the folder at path in a single zip archive, saved
+// as localPath.zip, or extracted on the fly into localPath if extract
+func (c *Client) DownloadZip(path, localPath string, extract bool) error {
+	meta, err := c.GetMetadata(path)
+	if err != nil {
+		return err
+	}
+	// the archive has all the files, whatever the TypeFilter
+	if meta.Tag != "folder" || len(TypeFilter) > 0 {
+		return ErrZipUnsuitable
+	}
+	entries, err := c.ListEntries(ListFolderArg{Path: path, Recursive: true})
+	if err != nil {
+		return err
+	}
+	var size int64
+	sizes := map[string]int64{}
+	depth := len(splitPath(path))
+	for _, e := range entries {
+		if e.Tag == "file" {
+			if e.Size >= maxZipFileSize {
+				return ErrZipUnsuitable
+			}
+			size += e.Size
+			sizes[strings.Join(splitPath(e.PathLower)[depth:], "/")] = e.Size
+		}
+	}
+	if size >= maxZipSize || len(entries) >= maxZipEntries {
+		return ErrZipUnsuitable
+	}
+	nsize, _ := utils.NiceBytes(size)
+	fmt.Printf("downloading %s as a zip archive: %d file(s), %s\n", path, len(sizes), nsize)
+	resp, err := c.openContent("/files/download_zip", map[string]string{"path": path})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if !extract {
+		out, err := os.Create(localPath + ".zip")
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, resp.Body)
+		// a failed write may only show when closing
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("saved %s (%d bytes)\n", localPath + ".zip", n)
+		return nil
+	}
+	files, n, err := ExtractZip(resp.Body, localPath, sizes)
+	fmt.Printf("extracted %d file(s), %d bytes into %s\n", files, n, localPath)
+	return err
+}
+
+// zip record signatures
+const(
+	zipLocalHeader = 0x04034b50
+	zipDescriptor  = 0x08074b50
+	zipCentralDir  = 0x02014b50
+	zipEnd         = 0x06054b50
+)
+
+type zipEntry struct {
+		name   string
+		flags  uint16
+		method uint16
+		crc    uint32
+		csize  uint64
+		usize  uint64
+		zip64  bool
+		mtime  time.Time
+}
+
+// ExtractZip extracts a zip archive read sequentially from r, without
+// seeking to its central directory, into dir. The top folder of the
+// archive is dropped. Each entry is checked against its CRC and sizes,
+// and against sizes, the expected sizes by lowercase relative path.
+func ExtractZip(r io.Reader, dir string, sizes map[string]int64) (files int, total int64, err error) {
+	br := bufio.NewReader(r)
+	for {
+		var sig uint32
+		if err = binary.Read(br, binary.LittleEndian, &sig); err != nil {
+			return files, total, fmt.Errorf("error: truncated zip archive: %v", err)
+		}
+		if sig == zipCentralDir || sig == zipEnd {
+			return files, total, nil
+		}
+		if sig != zipLocalHeader {
+			return files, total, fmt.Errorf("error: bad zip record signature %#x", sig)
+		}
+		ze, err := readZipHeader(br)
+		if err != nil {
+			return files, total, err
+		}
+		rel := ze.name
+		if k := strings.Index(rel, "/"); k >= 0 {
+			rel = rel[k+1:]
+		}
+		target, err := zipTarget(dir, rel)
+		if err != nil {
+			return files, total, err
+		}
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			if err = os.MkdirAll(target, 0777); err != nil {
+				return files, total, err
+			}
+			if err = skipZipData(br, ze); err != nil {
+				return files, total, err
+			}
+			continue
+		}
+		n, err := extractZipEntry(br, ze, target)
+		if err != nil {
+			return files, total, fmt.Errorf("error: %s: %v", ze.name, err)
+		}
+		if expected, ok := sizes[strings.ToLower(rel)]; ok && expected != n {
+			return files, total, fmt.Errorf("error: %s: size mismatch, expected: %d bytes, actual: %d bytes", ze.name, expected, n)
+		}
+		fmt.Println(target)
+		files += 1
+		total += n
+	}
+}
+
+func readZipHeader(br *bufio.Reader) (ze zipEntry, err error) {
+	var h struct {
+			Version, Flags, Method, Time, Date uint16
+			Crc, Csize, Usize                  uint32
+			NameLen, ExtraLen                  uint16
+	}
+	if err = binary.Read(br, binary.LittleEndian, &h); err != nil {
+		return
+	}
+	buf := make([]byte, int(h.NameLen) + int(h.ExtraLen))
+	if _, err = io.ReadFull(br, buf); err != nil {
+		return
+	}
+	ze = zipEntry{name: string(buf[:h.NameLen]), flags: h.Flags, method: h.Method, crc: h.Crc, csize: uint64(h.Csize), usize: uint64(h.Usize)}
+	ze.mtime = time.Date(1980 + int(h.Date>>9), time.Month(h.Date>>5&0xF), int(h.Date&0x1F),
+		int(h.Time>>11), int(h.Time>>5&0x3F), int(h.Time&0x1F)*2, 0, time.Local)
+	// the zip64 extra field holds the sizes that do not fit in 32 bits
+	extra := buf[h.NameLen:]
+	for len(extra) >= 4 {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		if 4+size > len(extra) {
+			break
+		}
+		if id == 0x0001 {
+			ze.zip64 = true
+			field := extra[4:4+size]
+			if h.Usize == 0xFFFFFFFF && len(field) >= 8 {
+				ze.usize, field = binary.LittleEndian.Uint64(field), field[8:]
+			}
+			if h.Csize == 0xFFFFFFFF && len(field) >= 8 {
+				ze.csize = binary.LittleEndian.Uint64(field)
+			}
+		}
+		extra = extra[4+size:]
+	}
+	return
+}
+
+// zipTarget returns where to extract name in dir, refusing names that
+// would escape it
+func zipTarget(dir, name string) (string, error) {
+	clean := ospath.Clean(ospath.FromSlash(name))
+	if ospath.IsAbs(clean) || ospath.VolumeName(clean) != "" || clean == ".." || strings.HasPrefix(clean, ".." + string(os.PathSeparator)) {
+		return "", fmt.Errorf("error: illegal path in zip archive: %q", name)
+	}
+	return ospath.Join(dir, clean), nil
+}
+
+// hasDescriptor reports whether the crc and sizes follow the data
+func (ze zipEntry) hasDescriptor() bool {
+	return ze.flags&0x8 != 0
+}
+
+// byteCounter counts the bytes read, and is still an io.ByteReader for flate
+type byteCounter struct {
+		*bufio.Reader
+		n int64
+}
+
+func (b *byteCounter) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *byteCounter) ReadByte() (byte, error) {
+	c, err := b.Reader.ReadByte()
+	if err == nil {
+		b.n += 1
+	}
+	return c, err
+}
+
+// readZipData copies the uncompressed data of ze to w, checking it against
+// the crc and sizes of its header or of the descriptor following it
+func readZipData(br *bufio.Reader, ze zipEntry, w io.Writer) (int64, error) {
+	hash := crc32.NewIEEE()
+	w = io.MultiWriter(w, hash)
+	var n int64
+	var err error
+	switch {
+	case ze.method == 0 && ze.hasDescriptor():
+		n, ze, err = copyStored(br, w, hash, ze)
+	case ze.method == 0:
+		n, err = io.Copy(w, io.LimitReader(br, int64(ze.csize)))
+	case ze.method == 8:
+		// flate reads no further than the end of the stream from an io.ByteReader
+		cr := &byteCounter{Reader: br}
+		var r io.Reader = cr
+		if !ze.hasDescriptor() {
+			r = bufio.NewReader(io.LimitReader(cr, int64(ze.csize)))
+		}
+		data := flate.NewReader(r)
+		n, err = io.Copy(w, data)
+		data.Close()
+		if err == nil && ze.hasDescriptor() {
+			if ze, err = readDescriptor(br, ze, uint64(cr.n), uint64(n)); err == nil && ze.csize != uint64(cr.n) {
+				err = fmt.Errorf("compressed size mismatch, expected: %d bytes, actual: %d bytes", ze.csize, cr.n)
+			}
+		}
+	default:
+		return 0, fmt.Errorf("unsupported compression method %d", ze.method)
+	}
+	if err != nil {
+		return n, err
+	}
+	if uint64(n) != ze.usize {
+		return n, fmt.Errorf("size mismatch, expected: %d bytes, actual: %d bytes", ze.usize, n)
+	}
+	if hash.Sum32() != ze.crc {
+		return n, fmt.Errorf("crc mismatch")
+	}
+	return n, nil
+}
+
+// copyStored copies the data of a stored entry whose size is only given
+// by the descriptor following it: the end of the data is the first
+// descriptor signature followed by the crc and sizes of what precedes it
+func copyStored(br *bufio.Reader, w io.Writer, hash hash.Hash32, ze zipEntry) (int64, zipEntry, error) {
+	var n int64
+	for {
+		if _, err := br.Peek(1); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return n, ze, err
+		}
+		buf, _ := br.Peek(br.Buffered())
+		k := bytes.IndexByte(buf, 'P')
+		if k < 0 {
+			k = len(buf)
+		}
+		if k == 0 {
+			if d, ok := storedEnd(br, ze, n, hash.Sum32()); ok {
+				return n, d, nil
+			}
+			k = 1
+		}
+		m, err := w.Write(buf[:k])
+		n += int64(m)
+		if err != nil {
+			return n, ze, err
+		}
+		br.Discard(k)
+	}
+}
+
+// storedEnd reads the descriptor at the head of br if it matches the n
+// bytes of crc crc read so far
+func storedEnd(br *bufio.Reader, ze zipEntry, n int64, crc uint32) (zipEntry, bool) {
+	size := 16
+	if ze.zip64 || n > 0xFFFFFFFF {
+		size = 24
+	}
+	d, err := br.Peek(size)
+	if err != nil || binary.LittleEndian.Uint32(d) != zipDescriptor || binary.LittleEndian.Uint32(d[4:]) != crc {
+		return ze, false
+	}
+	var csize, usize uint64
+	if size == 24 {
+		csize, usize = binary.LittleEndian.Uint64(d[8:]), binary.LittleEndian.Uint64(d[16:])
+	} else {
+		csize, usize = uint64(binary.LittleEndian.Uint32(d[8:])), uint64(binary.LittleEndian.Uint32(d[12:]))
+	}
+	if csize != uint64(n) || usize != uint64(n) {
+		return ze, false
+	}
+	br.Discard(size)
+	ze.crc, ze.csize, ze.usize = crc, csize, usize
+	return ze, true
+}
+
+func extractZipEntry(br *bufio.Reader, ze zipEntry, target string) (int64, error) {
+	if err := os.MkdirAll(ospath.Dir(target), 0777); err != nil {
+		return 0, err
+	}
+	out, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	n, err := readZipData(br, ze, out)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(target)
+		return n, err
+	}
+	os.Chtimes(target, ze.mtime, ze.mtime)
+	return n, nil
+}
+
+// readDescriptor reads the crc and sizes following the data of ze, whose
+// compressed and uncompressed sizes were csize and usize: the sizes are on
+// 64 bits for zip64 entries, and for entries too large for 32 bits even
+// without a zip64 header, as written by Go
+func readDescriptor(br *bufio.Reader, ze zipEntry, csize, usize uint64) (zipEntry, error) {
+	var v uint32
+	if err := binary.Read(br, binary.LittleEndian, &v); err != nil {
+		return ze, err
+	}
+	// the signature is optional
+	if v == zipDescriptor {
+		if err := binary.Read(br, binary.LittleEndian, &v); err != nil {
+			return ze, err
+		}
+	}
+	ze.crc = v
+	if ze.zip64 || csize > 0xFFFFFFFF || usize > 0xFFFFFFFF {
+		var sizes [2]uint64
+		err := binary.Read(br, binary.LittleEndian, &sizes)
+		ze.csize, ze.usize = sizes[0], sizes[1]
+		return ze, err
+	}
+	var sizes [2]uint32
+	err := binary.Read(br, binary.LittleEndian, &sizes)
+	ze.csize, ze.usize = uint64(sizes[0]), uint64(sizes[1])
+	return ze, err
+}
+
+// skipZipData skips the data of a folder entry, normally empty
+func skipZipData(br *bufio.Reader, ze zipEntry) error {
+	_, err := readZipData(br, ze, ioutil.Discard)
+	return err
+}
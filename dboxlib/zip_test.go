@@ -0,0 +1,210 @@
+package dboxlib
+
+import(
+	"os"
+	"bytes"
+	"bufio"
+	"strings"
+	"testing"
+	"io/ioutil"
+	"hash/crc32"
+	"archive/zip"
+	ospath "path/filepath"
+)
+
+type zipFile struct {
+		name   string
+		data   string
+		method uint16
+		raw    bool // stored with its sizes in the header, no descriptor
+}
+
+func buildZip(t *testing.T, files []zipFile) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, f := range files {
+		h := &zip.FileHeader{Name: f.name, Method: f.method}
+		if f.raw {
+			h.CRC32 = crc32.ChecksumIEEE([]byte(f.data))
+			h.CompressedSize64, h.UncompressedSize64 = uint64(len(f.data)), uint64(len(f.data))
+			fw, err := w.CreateRaw(h)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fw.Write([]byte(f.data))
+			continue
+		}
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(f.data))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractZip(t *testing.T) {
+	// data looking like a descriptor in a stored entry
+	tricky := "PK\x07\x08" + strings.Repeat("PK\x07\x08\x00\x00\x00\x00", 3) + "end"
+	long := strings.Repeat("0123456789abcdef", 1000)
+	tests := []struct {
+			name  string
+			files []zipFile
+			sizes map[string]int64
+			want  map[string]string
+			dirs  []string
+			err   string
+	}{
+		{"deflate", []zipFile{
+			{"top/", "", zip.Store, false},
+			{"top/a.txt", "hello", zip.Deflate, false},
+			{"top/Sub/b.txt", long, zip.Deflate, false},
+			{"top/empty/", "", zip.Deflate, false},
+		}, map[string]int64{"a.txt": 5, "sub/b.txt": int64(len(long))},
+			map[string]string{"a.txt": "hello", "Sub/b.txt": long}, []string{"empty"}, ""},
+		{"store with descriptor", []zipFile{
+			{"top/a.bin", tricky, zip.Store, false},
+			{"top/b.bin", "", zip.Store, false},
+			{"top/c.bin", long, zip.Store, false},
+		}, nil, map[string]string{"a.bin": tricky, "b.bin": "", "c.bin": long}, nil, ""},
+		{"store with sizes", []zipFile{
+			{"top/a.bin", tricky, zip.Store, true},
+			{"top/b.txt", "after", zip.Deflate, false},
+		}, nil, map[string]string{"a.bin": tricky, "b.txt": "after"}, nil, ""},
+		{"parent path", []zipFile{
+			{"top/../evil.txt", "x", zip.Deflate, false},
+		}, nil, nil, nil, "illegal path"},
+		{"absolute path", []zipFile{
+			{"top//etc/evil.txt", "x", zip.Store, false},
+		}, nil, nil, nil, "illegal path"},
+		{"size mismatch", []zipFile{
+			{"top/a.txt", "hello", zip.Deflate, false},
+		}, map[string]int64{"a.txt": 4}, nil, nil, "size mismatch"},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "dbox")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		files, _, err := ExtractZip(bytes.NewReader(buildZip(t, tt.files)), ospath.Join(dir, "out"), tt.sizes)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			if _, err = os.Stat(ospath.Join(dir, "evil.txt")); err == nil {
+				t.Errorf("%s: file extracted out of the folder", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for _, name := range tt.dirs {
+			if stat, err := os.Stat(ospath.Join(dir, "out", name)); err != nil || !stat.IsDir() {
+				t.Errorf("%s: folder %s not created", tt.name, name)
+			}
+		}
+		for name, data := range tt.want {
+			got, err := ioutil.ReadFile(ospath.Join(dir, "out", ospath.FromSlash(name)))
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			} else if string(got) != data {
+				t.Errorf("%s: %s has %d bytes, want %d", tt.name, name, len(got), len(data))
+			}
+		}
+		if files != len(tt.want) {
+			t.Errorf("%s: %d file(s) extracted, want %d", tt.name, files, len(tt.want))
+		}
+	}
+}
+
+func TestExtractZipCorrupt(t *testing.T) {
+	archive := buildZip(t, []zipFile{{"top/a.txt", "hello world", zip.Store, true}})
+	tests := []struct {
+			name string
+			data []byte
+			err  string
+	}{
+		{"truncated", archive[:40], "a.txt"},
+		{"crc", bytes.Replace(archive, []byte("hello"), []byte("jello"), 1), "crc mismatch"},
+		{"signature", append([]byte("junk"), archive...), "bad zip record signature"},
+		{"empty", nil, "truncated"},
+	}
+	for _, tt := range tests {
+		dir, err := ioutil.TempDir("", "dbox")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		_, _, err = ExtractZip(bytes.NewReader(tt.data), dir, nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestReadZipHeaderZip64(t *testing.T) {
+	// only the header is read: the sizes need not match any data
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	h := &zip.FileHeader{Name: "top/big.bin", Method: zip.Store, CRC32: 1}
+	h.CompressedSize64, h.UncompressedSize64 = 5<<30, 5<<30
+	if _, err := w.CreateRaw(h); err != nil {
+		t.Fatal(err)
+	}
+	w.Flush()
+	br := bufio.NewReader(bytes.NewReader(b.Bytes()[4:]))
+	ze, err := readZipHeader(br)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ze.zip64 || ze.usize != 5<<30 || ze.csize != 5<<30 || ze.name != "top/big.bin" || ze.hasDescriptor() {
+		t.Errorf("zip64 header = %+v", ze)
+	}
+}
+
+func TestReadDescriptor(t *testing.T) {
+	tests := []struct {
+			name         string
+			data         []byte
+			zip64        bool
+			csize, usize uint64
+			want         zipEntry
+	}{
+		{"signed", bytes.Join([][]byte{le32(zipDescriptor), le32(7), le32(10), le32(20)}, nil), false, 10, 20, zipEntry{crc: 7, csize: 10, usize: 20}},
+		{"unsigned", bytes.Join([][]byte{le32(7), le32(10), le32(20)}, nil), false, 10, 20, zipEntry{crc: 7, csize: 10, usize: 20}},
+		{"zip64", bytes.Join([][]byte{le32(zipDescriptor), le32(7), le32(10), le32(0), le32(20), le32(0)}, nil), true, 10, 20, zipEntry{crc: 7, csize: 10, usize: 20, zip64: true}},
+		{"large", bytes.Join([][]byte{le32(zipDescriptor), le32(7), le32(10), le32(0), le32(0), le32(1)}, nil), false, 10, 1<<32, zipEntry{crc: 7, csize: 10, usize: 1<<32}},
+	}
+	for _, tt := range tests {
+		ze, err := readDescriptor(bufio.NewReader(bytes.NewReader(tt.data)), zipEntry{zip64: tt.zip64}, tt.csize, tt.usize)
+		if err != nil || ze != tt.want {
+			t.Errorf("%s: readDescriptor = %+v, %v, want %+v", tt.name, ze, err, tt.want)
+		}
+	}
+}
+
+func TestZipTarget(t *testing.T) {
+	tests := []struct {
+			name string
+			ok   bool
+	}{
+		{"a/b.txt", true},
+		{"a/../b.txt", true},
+		{"..", false},
+		{"../b.txt", false},
+		{"a/../../b.txt", false},
+		{"/etc/passwd", false},
+		{"..b.txt", true},
+	}
+	for _, tt := range tests {
+		if _, err := zipTarget("dir", tt.name); (err == nil) != tt.ok {
+			t.Errorf("zipTarget(%q) error %v, want ok %v", tt.name, err, tt.ok)
+		}
+	}
+}